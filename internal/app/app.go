@@ -1,11 +1,13 @@
 package app
 
 import (
+	"context"
+	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
-	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 
 	"github.com/ddoemonn/go-dot-dot/internal/config"
@@ -15,34 +17,71 @@ import (
 	"github.com/ddoemonn/go-dot-dot/internal/utils"
 )
 
+// loadMoreThreshold is how close the cursor gets to the last loaded row
+// before the next page is requested
+const loadMoreThreshold = 50
+
 // App represents the application
 type App struct {
 	model  model.Model
 	db     *db.Database
 	styles *ui.Styles
 	keys   *ui.KeyMap
+
+	// Background work in flight, by kind; see startQuery
+	slots   [slotKinds]querySlot
+	initCmd tea.Cmd
+
+	pendingConfig    *config.Config             // Profile waiting for a password; see selectProfile
+	startTable       model.TableItem            // Relation to open once the table list is loaded
+	target           string                     // user@host:port/database of the connection; see connectionKey
+	confirmPrune     bool                       // The next D clears the query history
+	paramValues      map[string]string          // Last value entered for each statement parameter
+	editRow          []model.Cell               // Row being edited in the cell editor
+	editColumn       int                        // Index of the column being edited
+	marked           map[string][]model.Cell    // Rows selected for deleting, by key; see toggleMark
+	confirmDelete    bool                       // The next d deletes the rows
+	changes          []db.Change                // Pending changes while staging; see stageUpdate
+	committing       bool                       // The changes are being committed and can't change
+	confirmDiscard   bool                       // The next x rolls back the pending changes
+	confirmOverwrite bool                       // The next Enter in the file name prompt replaces the existing file
+	forceReadOnly    bool                       // Set by Options.ReadOnly
+	navStack         []navEntry                 // Relations left by following foreign keys; see navigate
+	tabDetails       tabLoadedMsg               // Catalog details on the tab shown
+	ddl              ddlLoadedMsg               // DDL in the DDL pane
+	pendingSave      func(*App, string) tea.Cmd // Saves to the file named in the prompt; see promptSave
+}
+
+// Options holds startup settings that are not part of the connection
+type Options struct {
+	Table    string // Relation to open on start, optionally schema qualified
+	ReadOnly bool   // Open every connection of the session read-only, whatever its profile says
 }
 
 // New creates a new application instance
-func New(cfg *config.Config) (*App, error) {
+func New(cfg *config.Config, opts Options) (*App, error) {
 	// Connect to the database
-	database, err := db.Connect(&cfg.DB)
+	database, err := db.Connect(context.Background(), &cfg.DB)
 	if err != nil {
 		return nil, err
 	}
 
-	// Fetch all table names
-	tables, err := database.FetchTables()
-	if err != nil {
-		return nil, err
+	// Fail before starting the UI when the requested relation doesn't exist
+	var startTable model.TableItem
+	if opts.Table != "" {
+		startTable, err = database.FindTable(context.Background(), opts.Table)
+		if err != nil {
+			database.Close()
+			return nil, err
+		}
 	}
 
 	// Initialize styles and keymap
 	styles := ui.NewStyles()
 	keys := ui.NewKeyMap()
 
-	// Create table list
-	tableList := ui.CreateTableList(tables, styles)
+	// Create table list; relations are loaded in the background by Init
+	tableList := ui.CreateTableList(nil, nil, styles)
 
 	// Set up search input
 	searchInput := ui.CreateSearchInput()
@@ -51,21 +90,35 @@ func New(cfg *config.Config) (*App, error) {
 	m := model.Model{
 		Pool:                   database.GetPool(),
 		TableList:              tableList,
-		Tables:                 tables,
-		Focused:                0,
+		Focused:                model.FocusTableList,
 		SearchInput:            searchInput,
 		Help:                   help.New(),
 		ShowHelp:               false,
 		ConnectionDetails:      cfg.DB.ConnectionDetails(),
+		ProfileList:            ui.CreateProfileList(nil, styles),
+		ActiveProfile:          cfg.Profile,
 		HorizontalScrollOffset: 0,
+		Spinner:                ui.CreateSpinner(),
+		QueryEditor:            ui.CreateQueryEditor(),
+		HistoryList:            ui.CreateHistoryList(styles),
+		ReferenceList:          ui.CreateReferenceList(styles),
+		FunctionList:           ui.CreateFunctionList(styles),
 	}
 
-	return &App{
-		model:  m,
-		db:     database,
-		styles: styles,
-		keys:   keys,
-	}, nil
+	m.PasswordInput = config.NewPasswordInput()
+
+	a := &App{
+		model:         m,
+		db:            database,
+		styles:        styles,
+		keys:          keys,
+		startTable:    startTable,
+		target:        cfg.DB.Target(),
+		forceReadOnly: opts.ReadOnly,
+	}
+	a.setReadOnly(database.ReadOnly())
+	a.initCmd = a.loadTables()
+	return a, nil
 }
 
 // Run starts the application
@@ -77,7 +130,7 @@ func (a *App) Run() error {
 
 // Init initializes the application
 func (a App) Init() tea.Cmd {
-	return nil
+	return a.initCmd
 }
 
 // Update handles messages and user input
@@ -87,6 +140,12 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		// Status messages only live until the next key press
+		a.model.StatusMessage = ""
+		// Deleting rows takes a second d; any other key calls it off
+		confirmDelete, confirmDiscard, confirmOverwrite := a.confirmDelete, a.confirmDiscard, a.confirmOverwrite
+		a.confirmDelete, a.confirmDiscard, a.confirmOverwrite = false, false, false
+
 		// Handle search mode separately
 		if a.model.SearchMode {
 			switch msg.String() {
@@ -94,13 +153,15 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				a.model.SearchMode = false
 				a.model.SearchQuery = a.model.SearchInput.Value()
 				// Apply filter
-				a.applySearchFilter()
-				return a, nil
+				return a, a.runSearch()
 			case "enter":
 				a.model.SearchMode = false
 				a.model.SearchQuery = a.model.SearchInput.Value()
 				// Apply filter
-				a.applySearchFilter()
+				return a, a.runSearch()
+			case "ctrl+t":
+				a.model.ServerSearch = !a.model.ServerSearch
+				a.model.SearchInput.Placeholder = searchPlaceholder(a.model.ServerSearch)
 				return a, nil
 			default:
 				var inputCmd tea.Cmd
@@ -109,93 +170,246 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		// The connection picker's password prompt takes all keys while open
+		if a.model.PasswordPrompt != "" {
+			switch msg.String() {
+			case "ctrl+c":
+				a.cancelQueries()
+				return a, tea.Quit
+			case "esc":
+				a.model.PasswordPrompt = ""
+				a.pendingConfig = nil
+				return a, nil
+			case "enter":
+				cfg := a.pendingConfig
+				cfg.DB.Password = a.model.PasswordInput.Value()
+				a.model.PasswordPrompt = ""
+				a.pendingConfig = nil
+				return a, a.connectProfile(cfg)
+			default:
+				var inputCmd tea.Cmd
+				a.model.PasswordInput, inputCmd = a.model.PasswordInput.Update(msg)
+				return a, inputCmd
+			}
+		}
+
+		// The file name prompt takes all keys while it is open
+		if a.model.SavePrompt != "" {
+			return a, a.updateSavePrompt(msg, confirmOverwrite)
+		}
+
+		// The cell editor takes all keys while it is open
+		if a.model.Editing {
+			return a, a.updateEdit(msg)
+		}
+
+		// The insert form takes all keys while it is open
+		if a.model.Focused == model.FocusInsert {
+			return a, a.updateInsertForm(msg)
+		}
+
+		// The parameters form takes all keys while it is open
+		if a.model.Focused == model.FocusParams {
+			return a, a.updateParams(msg)
+		}
+
+		// The SQL editor takes all keys while it has the focus
+		if a.model.Focused == model.FocusQuery && !a.model.QueryResultsFocused {
+			return a, a.updateQueryEditor(msg)
+		}
+
+		// The history filter takes all keys while it is being typed
+		if a.historyFiltering() {
+			var listCmd tea.Cmd
+			a.model.HistoryList, listCmd = a.model.HistoryList.Update(msg)
+			return a, listCmd
+		}
+
+		// So does the functions filter
+		if a.functionsFiltering() {
+			var listCmd tea.Cmd
+			a.model.FunctionList, listCmd = a.model.FunctionList.Update(msg)
+			return a, listCmd
+		}
+
 		// Global keys
 		switch {
 		case key.Matches(msg, a.keys.Quit):
+			a.cancelQueries()
 			return a, tea.Quit
+		case key.Matches(msg, a.keys.Cancel):
+			if a.cancelQueries() {
+				a.model.StatusMessage = "Cancelling query..."
+			}
+			return a, nil
 		case key.Matches(msg, a.keys.Help):
 			a.model.ShowHelp = !a.model.ShowHelp
 			return a, nil
 		case key.Matches(msg, a.keys.Search):
-			if a.model.Focused == 1 && len(a.model.Data) > 0 { // Only allow search in table view with data
+			if a.showingData() && len(a.model.ColumnNames) > 0 { // Only allow search in table view with columns
 				a.model.SearchMode = true
 				a.model.SearchInput.Focus()
-				a.model.SearchInput.Placeholder = "Type to search table..."
+				a.model.SearchInput.Placeholder = searchPlaceholder(a.model.ServerSearch)
+				return a, nil
+			}
+		case key.Matches(msg, a.keys.SearchScope):
+			if a.showingData() {
+				a.model.ServerSearch = !a.model.ServerSearch
+				if a.model.SearchQuery != "" {
+					return a, a.runSearch()
+				}
 				return a, nil
 			}
 		case key.Matches(msg, a.keys.ClearSearch):
-			if a.model.Focused == 1 && a.model.SearchQuery != "" {
+			if a.showingData() && a.model.SearchQuery != "" {
 				a.model.SearchQuery = ""
 				a.model.SearchInput.Reset()
-				a.model.FilteredData = a.model.Data
-				if len(a.model.ColumnNames) > 0 && len(a.model.Data) > 0 {
-					a.model.TableData = ui.CreateTableData(a.model.ColumnNames, a.model.FilteredData, a.model.HorizontalScrollOffset)
-				}
+				return a, a.runSearch()
 			}
 			return a, nil
+		case key.Matches(msg, a.keys.Connections):
+			if len(a.changes) > 0 {
+				a.model.StatusMessage = "Commit or roll back the pending changes before switching connections"
+				return a, nil
+			}
+			if a.model.Focused != model.FocusConnections {
+				a.openConnectionPicker()
+			}
+			return a, nil
+		case key.Matches(msg, a.keys.QueryEditor):
+			return a, a.openQueryEditor()
+		case key.Matches(msg, a.keys.History):
+			return a, a.openHistory()
 		case key.Matches(msg, a.keys.Back):
 			// Back button behavior depends on current view
-			if a.model.Focused == 2 { // Detail view -> Table view
-				a.model.Focused = 1
+			if a.model.Focused == model.FocusConnections { // Connection picker -> Table list
+				a.model.Focused = model.FocusTableList
+				return a, nil
+			} else if a.model.Focused == model.FocusDetail { // Detail view -> Table view or query results
+				a.model.Focused = a.model.DetailReturn
+				return a, nil
+			} else if a.model.Focused == model.FocusQuery { // Query results -> SQL editor
+				return a, a.focusQueryEditor()
+			} else if a.model.Focused == model.FocusHistory { // Query history -> SQL editor
+				return a, a.leaveHistory()
+			} else if a.model.Focused == model.FocusChanges { // Pending changes -> Table view
+				a.closeChanges()
+				return a, nil
+			} else if a.model.Focused == model.FocusDDL { // DDL pane -> where it was opened from
+				a.model.Focused = a.model.DDLReturn
 				return a, nil
-			} else if a.model.Focused == 1 { // Table view -> Table list
-				a.model.Focused = 0
-				a.model.SelectedTable = ""
+			} else if a.model.Focused == model.FocusFunctions { // Functions picker -> Table list
+				a.leaveFunctions()
 				return a, nil
+			} else if a.model.Focused == model.FocusReferences { // Referencing rows -> Detail view
+				a.model.Focused = model.FocusDetail
+				return a, nil
+			} else if a.model.Focused == model.FocusTableData && len(a.navStack) > 0 { // Followed relation -> the one it was reached from
+				return a, a.navigateBack()
+			} else if a.model.Focused == model.FocusTableData { // Table view -> Table list
+				a.model.Focused = model.FocusTableList
+				a.model.SelectedTable = model.TableItem{}
+				return a, nil
+			}
+		case key.Matches(msg, a.keys.RefreshView):
+			target := a.model.SelectedTable
+			if a.model.Focused == model.FocusTableList {
+				if i, ok := a.model.TableList.SelectedItem().(model.TableItem); ok {
+					target = i
+				}
+			}
+			if target.Kind != model.KindMaterializedView {
+				break
 			}
+			return a, a.refreshView(target)
 		// Handle horizontal scrolling
 		case key.Matches(msg, a.keys.ScrollLeft):
-			if a.model.Focused == 1 && a.model.HorizontalScrollOffset > 0 {
-				a.model.HorizontalScrollOffset--
-				if len(a.model.ColumnNames) > 0 && len(a.model.FilteredData) > 0 {
-					a.model.TableData = ui.CreateTableData(a.model.ColumnNames, a.model.FilteredData, a.model.HorizontalScrollOffset)
-				}
+			if a.model.Focused == model.FocusQuery && a.model.QueryScrollOffset > 0 {
+				a.model.QueryScrollOffset--
+				a.rebuildQueryTable()
+				return a, nil
+			}
+			if a.showingData() && a.model.HorizontalScrollOffset > 0 {
+				a.scrollColumns(-1)
 				return a, nil
 			}
 		case key.Matches(msg, a.keys.ScrollRight):
-			if a.model.Focused == 1 && a.model.HorizontalScrollOffset < len(a.model.ColumnNames)-1 {
-				a.model.HorizontalScrollOffset++
-				if len(a.model.ColumnNames) > 0 && len(a.model.FilteredData) > 0 {
-					a.model.TableData = ui.CreateTableData(a.model.ColumnNames, a.model.FilteredData, a.model.HorizontalScrollOffset)
-				}
+			if a.model.Focused == model.FocusQuery && a.model.QueryResult != nil &&
+				a.model.QueryScrollOffset < len(a.model.QueryResult.Columns)-1 {
+				a.model.QueryScrollOffset++
+				a.rebuildQueryTable()
+				return a, nil
+			}
+			if a.showingData() && a.model.HorizontalScrollOffset < len(a.model.ColumnNames)-1 {
+				a.scrollColumns(1)
 				return a, nil
 			}
 		}
 
 		// Handle based on focus
-		if a.model.Focused == 0 { // Table list
+		if a.model.Focused == model.FocusTableList { // Table list
 			switch {
 			case key.Matches(msg, a.keys.Right), key.Matches(msg, a.keys.Select):
-				if len(a.model.Tables) > 0 {
-					i, ok := a.model.TableList.SelectedItem().(list.Item)
-					if ok {
-						a.model.SelectedTable = i.FilterValue()
-						var err error
-						a.model.Data, a.model.ColumnNames, err = a.db.FetchTableData(a.model.SelectedTable)
-						if err != nil {
-							a.model.Err = err
-							return a, nil
-						}
-						a.model.FilteredData = a.model.Data
-						a.model.SearchQuery = ""
-						a.model.SearchInput.Reset()
-						// Reset horizontal scroll when selecting a new table
-						a.model.HorizontalScrollOffset = 0
-
-						if len(a.model.ColumnNames) > 0 && len(a.model.Data) > 0 {
-							a.model.TableData = ui.CreateTableData(a.model.ColumnNames, a.model.Data, a.model.HorizontalScrollOffset)
-						}
-						a.model.Focused = 1
-					}
+				switch i := a.model.TableList.SelectedItem().(type) {
+				case model.TableItem:
+					return a, a.openTable(i)
+				case model.SavedQueryItem:
+					return a, a.openSavedQuery(i)
 				}
+			case key.Matches(msg, a.keys.ShowDDL):
+				return a, a.showDDL()
+			case key.Matches(msg, a.keys.Functions):
+				return a, a.loadFunctions()
 			}
 			a.model.TableList, cmd = a.model.TableList.Update(msg)
 			cmds = append(cmds, cmd)
-		} else if a.model.Focused == 1 { // Table data
+		} else if a.model.Focused == model.FocusTableData && a.model.TableTab != model.TabData { // Structure and other tabs
+			cmds = append(cmds, a.updateTab(msg))
+		} else if a.model.Focused == model.FocusTableData { // Table data
 			switch {
+			case key.Matches(msg, a.keys.SwitchPane):
+				return a, a.switchTab()
 			case key.Matches(msg, a.keys.Left):
-				// Go back to table list
-				a.model.Focused = 0
+				if !a.moveColumn(-1) {
+					// Go back to table list from the first column
+					a.model.Focused = model.FocusTableList
+				}
+				return a, nil
+			case key.Matches(msg, a.keys.Right):
+				a.moveColumn(1)
+				return a, nil
+			case key.Matches(msg, a.keys.HideColumn):
+				a.hideColumn()
+				return a, nil
+			case key.Matches(msg, a.keys.ShowColumns):
+				a.showColumns()
+				return a, nil
+			case key.Matches(msg, a.keys.Sort):
+				return a, a.toggleSort(false)
+			case key.Matches(msg, a.keys.AddSort):
+				return a, a.toggleSort(true)
+			case key.Matches(msg, a.keys.Edit):
+				return a, a.startEdit()
+			case key.Matches(msg, a.keys.Insert):
+				return a, a.openInsertForm()
+			case key.Matches(msg, a.keys.DeleteRows):
+				return a, a.deleteRows(confirmDelete)
+			case key.Matches(msg, a.keys.Mark):
+				a.toggleMark()
+				return a, a.loadMoreIfNeeded()
+			case key.Matches(msg, a.keys.Stage):
+				a.toggleStaging()
+				return a, nil
+			case key.Matches(msg, a.keys.Review):
+				a.openChanges()
+				return a, nil
+			case key.Matches(msg, a.keys.ShowDDL):
+				return a, a.showDDL()
+			case key.Matches(msg, a.keys.Export):
+				a.exportView()
+				return a, nil
+			case key.Matches(msg, a.keys.ExportAll):
+				a.exportTable()
 				return a, nil
 			case key.Matches(msg, a.keys.ViewDetails), key.Matches(msg, a.keys.Select):
 				// View details of selected row
@@ -203,21 +417,183 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					rowIndex := a.model.TableData.Cursor()
 					if rowIndex >= 0 && rowIndex < len(a.model.FilteredData) {
 						a.model.SelectedRow = rowIndex
-						a.model.SelectedRowData = make(map[string]string)
-						for i, col := range a.model.ColumnNames {
-							if i < len(a.model.FilteredData[rowIndex]) {
-								a.model.SelectedRowData[col] = a.model.FilteredData[rowIndex][i]
-							}
-						}
-						a.model.Focused = 2 // Switch to detail view
+						a.model.SelectedRowData = rowData(a.model.ColumnNames, a.model.FilteredData[rowIndex])
+						a.model.DetailReturn = model.FocusTableData
+						a.model.DetailCursor = 0
+						a.model.Focused = model.FocusDetail // Switch to detail view
 					}
 				}
 			default:
 				a.model.TableData, cmd = a.model.TableData.Update(msg)
+				cmds = append(cmds, cmd, a.loadMoreIfNeeded())
+			}
+		} else if a.model.Focused == model.FocusDetail { // Detail view
+			switch {
+			case key.Matches(msg, a.keys.Up):
+				if a.model.DetailCursor > 0 {
+					a.model.DetailCursor--
+				}
+			case key.Matches(msg, a.keys.Down):
+				if a.model.DetailCursor < len(a.model.SelectedRowData)-1 {
+					a.model.DetailCursor++
+				}
+			case key.Matches(msg, a.keys.Edit):
+				return a, a.startEdit()
+			case key.Matches(msg, a.keys.Follow):
+				return a, a.followReference()
+			case key.Matches(msg, a.keys.References):
+				a.showReferences()
+				return a, nil
+			}
+		} else if a.model.Focused == model.FocusDDL { // DDL pane
+			cmds = append(cmds, a.updateDDL(msg))
+		} else if a.model.Focused == model.FocusFunctions { // Functions picker
+			cmds = append(cmds, a.updateFunctions(msg))
+		} else if a.model.Focused == model.FocusReferences { // Referencing rows picker
+			cmds = append(cmds, a.updateReferences(msg))
+		} else if a.model.Focused == model.FocusQuery { // Query results; the editor is handled above
+			switch {
+			case key.Matches(msg, a.keys.SwitchPane):
+				return a, a.focusQueryEditor()
+			case key.Matches(msg, a.keys.Export):
+				a.exportView()
+				return a, nil
+			case key.Matches(msg, a.keys.ViewDetails), key.Matches(msg, a.keys.Select):
+				a.showQueryRowDetails()
+			default:
+				a.model.QueryTable, cmd = a.model.QueryTable.Update(msg)
 				cmds = append(cmds, cmd)
 			}
-		} else if a.model.Focused == 2 { // Detail view
-			// No special handling needed for detail view beyond global keys
+		} else if a.model.Focused == model.FocusHistory { // Query history
+			cmds = append(cmds, a.updateHistory(msg))
+		} else if a.model.Focused == model.FocusChanges { // Pending changes
+			cmds = append(cmds, a.updateChanges(msg, confirmDiscard))
+		} else if a.model.Focused == model.FocusConnections { // Connection picker
+			if key.Matches(msg, a.keys.Select) {
+				if i, ok := a.model.ProfileList.SelectedItem().(model.ProfileItem); ok {
+					return a, a.selectProfile(i.Name)
+				}
+			}
+			a.model.ProfileList, cmd = a.model.ProfileList.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case spinner.TickMsg:
+		if a.model.Loading != "" {
+			a.model.Spinner, cmd = a.model.Spinner.Update(msg)
+			cmds = append(cmds, cmd)
+		}
+
+	case queryDoneMsg:
+		if !a.finish(msg) {
+			// Replaced by newer work; a connection it opened is not needed
+			if connected, ok := msg.msg.(connectedMsg); ok && connected.database != nil {
+				connected.database.Close()
+			}
+			return a, nil
+		}
+		return a.Update(msg.msg)
+
+	case connectedMsg:
+		if msg.err != nil {
+			a.model.StatusMessage = fmt.Sprintf("Failed to connect to %s: %s", msg.profile.Name, queryError(msg.err))
+			return a, nil
+		}
+		return a, a.switchConnection(msg)
+
+	case tablesLoadedMsg:
+		if msg.err != nil {
+			a.model.Err = msg.err
+			return a, nil
+		}
+		a.model.Tables = msg.tables
+		a.loadSavedQueries()
+		cmds = append(cmds, a.model.TableList.SetItems(ui.CreateTableItems(msg.tables, a.model.SavedQueries)))
+		if a.startTable.Name != "" {
+			for i, item := range a.model.TableList.Items() {
+				if item == a.startTable {
+					a.model.TableList.Select(i)
+				}
+			}
+			cmds = append(cmds, a.openTable(a.startTable))
+			a.startTable = model.TableItem{}
+		}
+
+	case tableOpenedMsg:
+		if msg.err != nil {
+			a.model.StatusMessage = fmt.Sprintf("Failed to open %s: %s", msg.table.QualifiedName(), queryError(msg.err))
+			return a, nil
+		}
+		a.showTable(msg)
+		a.model.Focused = model.FocusTableData
+		if msg.match == nil {
+			cmds = append(cmds, a.countRows())
+		}
+
+	case rowsCountedMsg:
+		if msg.table != a.model.SelectedTable || a.model.Paging.Match != nil || a.model.Paging.Filter != "" {
+			return a, nil
+		}
+		if msg.err != nil {
+			a.model.StatusMessage = fmt.Sprintf("Failed to count the rows of %s: %s", msg.table.QualifiedName(), queryError(msg.err))
+			return a, nil
+		}
+		a.model.Paging.TotalRows = msg.total
+		a.model.Paging.Estimated = !msg.exact
+
+	case pageLoadedMsg:
+		if msg.table != a.model.SelectedTable || msg.generation != a.model.Paging.Generation {
+			// The user moved on to another relation, search or sort; drop the stale page
+			return a, nil
+		}
+		a.model.Paging.Loading = false
+		if msg.err != nil {
+			a.model.StatusMessage = fmt.Sprintf("Failed to load rows: %s", queryError(msg.err))
+			return a, nil
+		}
+		if msg.reset {
+			a.replaceData(msg.page)
+			a.restoreCursor(msg.restore)
+		} else {
+			a.appendPage(msg.page)
+		}
+
+	case cellUpdatedMsg:
+		a.applyCellUpdate(msg)
+
+	case tabLoadedMsg:
+		a.showTab(msg)
+
+	case ddlLoadedMsg:
+		a.openDDL(msg)
+
+	case functionsLoadedMsg:
+		a.showFunctions(msg)
+
+	case exportDoneMsg:
+		a.showExport(msg)
+
+	case columnsLoadedMsg:
+		return a, a.showInsertForm(msg)
+
+	case rowsChangedMsg:
+		return a, a.applyRowsChanged(msg)
+
+	case changesAppliedMsg:
+		return a, a.applyCommit(msg)
+
+	case queryResultMsg:
+		a.showQueryResult(msg)
+		a.recordHistory(msg)
+
+	case viewRefreshedMsg:
+		if msg.err != nil {
+			a.model.StatusMessage = fmt.Sprintf("Refresh failed: %s", queryError(msg.err))
+			return a, nil
+		}
+		a.model.StatusMessage = fmt.Sprintf("Refreshed %s", msg.table.QualifiedName())
+		if a.model.Focused == model.FocusTableData && msg.table == a.model.SelectedTable {
+			return a, a.fetchPage(true)
 		}
 
 	case tea.WindowSizeMsg:
@@ -228,6 +604,16 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		listWidth := utils.Min(30, a.model.Width/4)
 		a.model.TableList.SetWidth(listWidth)
 		a.model.TableList.SetHeight(a.model.Height - 8) // Leave space for headers and footers
+		a.model.ProfileList.SetWidth(a.model.Width - 10)
+		a.model.ProfileList.SetHeight(a.model.Height - 8)
+		a.model.HistoryList.SetWidth(a.model.Width - 10)
+		a.model.HistoryList.SetHeight(a.model.Height - 16)
+		a.model.ReferenceList.SetWidth(a.model.Width - 10)
+		a.model.ReferenceList.SetHeight(a.model.Height - 12)
+		a.model.FunctionList.SetWidth(a.model.Width - 10)
+		a.model.FunctionList.SetHeight(a.model.Height - 12)
+		a.model.DDLView.Width = a.model.Width - 12
+		a.model.DDLView.Height = a.model.Height - 14
 
 		// Adjust table data
 		headerHeight := 6
@@ -238,6 +624,12 @@ func (a App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			a.model.TableData.SetWidth(a.model.Width - listWidth - 8)
 		}
 
+		a.resizeTabs()
+
+		// Adjust the SQL editor and its result grid
+		a.model.QueryEditor.SetWidth(a.model.Width - 10)
+		a.resizeQueryTable()
+
 		// Update styles based on width
 		a.styles.TableListHeader = a.styles.TableListHeader.Width(listWidth)
 	}
@@ -250,31 +642,389 @@ func (a App) View() string {
 	return ui.RenderView(&a.model, a.styles, a.keys)
 }
 
-// Apply search filter to table data
-func (a *App) applySearchFilter() {
-	if a.model.SearchQuery == "" {
+// openConnectionPicker lists the saved connection profiles
+func (a *App) openConnectionPicker() {
+	profiles, err := config.LoadProfiles()
+	if err != nil {
+		a.model.StatusMessage = err.Error()
+		return
+	}
+	if len(profiles.Profiles) == 0 {
+		path, _ := config.ProfilesPath()
+		a.model.StatusMessage = fmt.Sprintf("No connection profiles found. Add them to %s", path)
+		return
+	}
+
+	items := make([]model.ProfileItem, len(profiles.Profiles))
+	for i, profile := range profiles.Profiles {
+		items[i] = model.ProfileItem{Name: profile.Name, Details: profile.DB.ConnectionDetails()}
+		if profile.Name == a.model.ActiveProfile {
+			items[i].Details += " (current)"
+		}
+	}
+	a.model.Focused = model.FocusConnections
+	a.model.ProfileList.SetItems(ui.CreateProfileItems(items))
+}
+
+// selectProfile connects to a saved profile, first asking for its password
+// when the profile does not store one
+func (a *App) selectProfile(name string) tea.Cmd {
+	cfg, err := config.LoadProfile(name)
+	if err != nil {
+		a.model.StatusMessage = err.Error()
+		return nil
+	}
+	if cfg.PromptPassword {
+		a.pendingConfig = cfg
+		a.model.PasswordPrompt = name
+		a.model.PasswordInput.Reset()
+		return a.model.PasswordInput.Focus()
+	}
+	return a.connectProfile(cfg)
+}
+
+// setReadOnly shows whether the connection is read-only and turns the keys
+// that change data off or on to match
+func (a *App) setReadOnly(readOnly bool) {
+	a.model.ReadOnly = readOnly
+	a.keys.SetReadOnly(readOnly)
+	if readOnly {
+		a.model.Staging = false
+	}
+}
+
+// switchConnection replaces the database connection with a newly opened one
+// and reloads the table list
+func (a *App) switchConnection(msg connectedMsg) tea.Cmd {
+	// Closing waits for the connections in use; reads of the old database
+	// are of no use any more, so stop them first
+	a.cancelSlot(slotPage)
+	a.cancelSlot(slotCount)
+	a.cancelSlot(slotExport)
+	a.db.Close()
+	a.db = msg.database
+
+	a.model.Pool = a.db.GetPool()
+	a.model.ActiveProfile = msg.profile.Name
+	a.model.ConnectionDetails = msg.profile.DB.ConnectionDetails()
+	a.target = msg.profile.DB.Target()
+	a.setReadOnly(a.db.ReadOnly())
+	a.model.Tables = nil
+	a.model.SelectedTable = model.TableItem{}
+	a.model.Data = nil
+	a.model.FilteredData = nil
+	a.model.ColumnNames = nil
+	a.model.SearchQuery = ""
+	a.model.SearchInput.Reset()
+	a.model.SortKeys = nil
+	a.model.Paging = model.PageState{Generation: a.model.Paging.Generation + 1}
+	a.model.Focused = model.FocusTableList
+	a.model.TableList.SetItems(nil)
+	a.model.StatusMessage = fmt.Sprintf("Switched to %s", msg.profile.Name)
+
+	return a.loadTables()
+}
+
+// showTable puts a freshly opened relation into the data pane
+func (a *App) showTable(msg tableOpenedMsg) {
+	a.model.SelectedTable = msg.table
+	a.model.TableTab = model.TabData
+	a.marked = nil
+	a.model.Data = msg.page.Rows
+	a.model.ColumnNames = msg.page.Columns
+	a.model.FilteredData = a.model.Data
+	a.model.SearchQuery = ""
+	a.model.SearchInput.Reset()
+	a.model.SortKeys = nil
+	a.model.ForeignKeys = msg.foreignKeys
+	a.model.ReferencingKeys = msg.referencing
+	a.setTrail(msg.trail)
+	a.model.Paging = model.PageState{
+		Match:      msg.match,
+		KeyColumns: msg.keyColumns,
+		LastKey:    msg.page.LastKey,
+		HasMore:    msg.page.HasMore,
+		TotalRows:  -1, // Until countRows reports
+		Generation: a.model.Paging.Generation + 1,
+	}
+	// Reset horizontal scroll when selecting a new table
+	a.model.HorizontalScrollOffset = 0
+	a.model.ColumnCursor = 0
+	a.model.HiddenColumns = nil
+
+	// A followed key can match no rows; show its columns all the same
+	if len(a.model.ColumnNames) > 0 {
+		a.model.TableData = ui.CreateDataGrid(a.model.ColumnNames, a.model.Data, a.model.HorizontalScrollOffset, a.model.ColumnCursor, a.model.SortKeys, a.model.HiddenColumns)
+	}
+}
+
+// loadMoreIfNeeded requests the next page once the cursor nears the end of
+// the loaded rows
+func (a *App) loadMoreIfNeeded() tea.Cmd {
+	if !a.model.Paging.HasMore || a.model.Paging.Loading {
+		return nil
+	}
+	if a.model.TableData.Cursor() < len(a.model.FilteredData)-loadMoreThreshold {
+		return nil
+	}
+	return a.fetchPage(false)
+}
+
+// replaceData swaps the loaded rows for a freshly fetched first page
+func (a *App) replaceData(page *db.Page) {
+	a.model.Data = page.Rows
+	a.model.Paging.LastKey = page.LastKey
+	a.model.Paging.HasMore = page.HasMore
+	a.applySearchFilter()
+	if len(a.model.FilteredData) == 0 {
+		a.model.TableData.SetRows(nil)
+	}
+}
+
+// appendPage adds a freshly loaded page to the data pane, keeping the cursor
+func (a *App) appendPage(page *db.Page) {
+	a.model.Data = append(a.model.Data, page.Rows...)
+	a.model.Paging.LastKey = page.LastKey
+	a.model.Paging.HasMore = page.HasMore
+
+	if a.model.SearchQuery == "" || a.model.Paging.Filter != "" {
 		a.model.FilteredData = a.model.Data
 	} else {
-		searchLower := strings.ToLower(a.model.SearchQuery)
-		a.model.FilteredData = nil
-
-		// Search through all rows and all columns
-		for _, row := range a.model.Data {
-			found := false
-			for _, cell := range row {
-				if strings.Contains(strings.ToLower(cell), searchLower) {
-					found = true
-					break
-				}
-			}
-			if found {
-				a.model.FilteredData = append(a.model.FilteredData, row)
+		a.model.FilteredData = append(a.model.FilteredData, a.filterRows(page.Rows)...)
+	}
+	if len(a.model.ColumnNames) == 0 || len(a.model.FilteredData) == 0 {
+		return
+	}
+	if len(a.model.TableData.Columns()) == 0 {
+		a.rebuildGrid()
+	} else {
+		a.refreshRows()
+	}
+}
+
+// rebuildGrid recreates the data grid from the filtered rows, for a change of
+// columns, scroll or sort markers. The row cursor and size are kept.
+func (a *App) rebuildGrid() {
+	cursor := a.model.TableData.Cursor()
+	height, width := a.model.TableData.Height(), a.model.TableData.Width()
+	a.model.TableData = ui.CreateDataGrid(a.model.ColumnNames, a.model.FilteredData, a.model.HorizontalScrollOffset, a.model.ColumnCursor, a.model.SortKeys, a.model.HiddenColumns)
+	if height > 0 {
+		a.model.TableData.SetHeight(height)
+		a.model.TableData.SetWidth(width)
+	}
+	a.refreshRows()
+	a.model.TableData.SetCursor(utils.Max(utils.Min(cursor, len(a.model.TableData.Rows())-1), 0))
+}
+
+// visibleColumns returns how many columns the data grid shows from the
+// horizontal scroll position
+func (a *App) visibleColumns() int {
+	return ui.VisibleColumns(a.model.ColumnNames, a.model.HorizontalScrollOffset, a.model.ColumnCursor,
+		a.model.SortKeys, a.model.HiddenColumns, a.model.TableData.Width())
+}
+
+// moveColumn moves the column cursor by delta, over hidden columns, and
+// scrolls the grid to keep the column in view. It reports whether there was
+// a column to move to.
+func (a *App) moveColumn(delta int) bool {
+	cursor := a.model.ColumnCursor + delta
+	for cursor >= 0 && cursor < len(a.model.ColumnNames) && a.columnHidden(cursor) {
+		cursor += delta
+	}
+	if cursor < 0 || cursor >= len(a.model.ColumnNames) {
+		return false
+	}
+	a.model.ColumnCursor = cursor
+	if cursor < a.model.HorizontalScrollOffset {
+		a.model.HorizontalScrollOffset = cursor
+	}
+	for cursor >= a.model.HorizontalScrollOffset+a.visibleColumns() {
+		a.model.HorizontalScrollOffset++
+	}
+	a.rebuildGrid()
+	return true
+}
+
+// scrollColumns scrolls the data grid by delta columns, taking the column
+// cursor along when it would leave the view
+func (a *App) scrollColumns(delta int) {
+	a.model.HorizontalScrollOffset += delta
+	first, last := a.model.HorizontalScrollOffset, a.model.HorizontalScrollOffset+a.visibleColumns()-1
+	if a.model.ColumnCursor < first {
+		a.model.ColumnCursor = first
+	}
+	if a.model.ColumnCursor > last {
+		a.model.ColumnCursor = last
+	}
+	// Land on a column that is shown, preferring the direction of the scroll
+	for _, step := range []int{delta, -delta} {
+		cursor := a.model.ColumnCursor
+		for cursor >= first && cursor <= last && a.columnHidden(cursor) {
+			cursor += step
+		}
+		if cursor >= first && cursor <= last {
+			a.model.ColumnCursor = cursor
+			break
+		}
+	}
+	a.rebuildGrid()
+}
+
+// columnHidden reports whether the column at an index is left out of the grid
+func (a *App) columnHidden(index int) bool {
+	return a.model.HiddenColumns[a.model.ColumnNames[index]]
+}
+
+// hideColumn leaves the column under the column cursor out of the grid and
+// of exports of the view, moving the cursor to the next column shown
+func (a *App) hideColumn() {
+	if len(a.model.ColumnNames) == 0 {
+		return
+	}
+	if len(a.model.HiddenColumns) == len(a.model.ColumnNames)-1 {
+		a.model.StatusMessage = "The last column shown can't be hidden"
+		return
+	}
+	column := a.model.ColumnNames[a.model.ColumnCursor]
+	if a.model.HiddenColumns == nil {
+		a.model.HiddenColumns = map[string]bool{}
+	}
+	a.model.HiddenColumns[column] = true
+	if !a.moveColumn(1) {
+		a.moveColumn(-1)
+	}
+	a.model.StatusMessage = fmt.Sprintf("Hid %s; press %s to show all columns", column, a.keys.ShowColumns.Help().Key)
+}
+
+// showColumns brings the hidden columns back into the grid
+func (a *App) showColumns() {
+	if len(a.model.HiddenColumns) == 0 {
+		return
+	}
+	a.model.HiddenColumns = nil
+	a.rebuildGrid()
+}
+
+// toggleSort cycles the sort on the column under the column cursor through
+// ascending, descending and unsorted, then reloads the relation in the new
+// order. With multi set the column is added to the existing sort keys
+// instead of replacing them.
+func (a *App) toggleSort(multi bool) tea.Cmd {
+	if len(a.model.ColumnNames) == 0 {
+		return nil
+	}
+	column := a.model.ColumnNames[a.model.ColumnCursor]
+
+	var sortKeys []model.SortKey
+	found := false
+	for _, sortKey := range a.model.SortKeys {
+		if sortKey.Column == column {
+			found = true
+			if !sortKey.Descending {
+				sortKey.Descending = true
+				sortKeys = append(sortKeys, sortKey)
 			}
+			continue
 		}
+		if multi {
+			sortKeys = append(sortKeys, sortKey)
+		}
+	}
+	if !found {
+		sortKeys = append(sortKeys, model.SortKey{Column: column})
+	}
+
+	a.model.SortKeys = sortKeys
+	return a.fetchPage(true)
+}
+
+// runSearch applies SearchQuery, either to the loaded rows or by reloading
+// the relation with the filter pushed down to PostgreSQL
+func (a *App) runSearch() tea.Cmd {
+	filter := ""
+	if a.model.ServerSearch {
+		filter = a.model.SearchQuery
+	}
+	if filter == a.model.Paging.Filter {
+		a.applySearchFilter()
+		return nil
+	}
+	a.model.Paging.Filter = filter
+	if filter == "" && a.model.Paging.TotalRows < 0 {
+		// A count that came back while the filter was on was dropped
+		return tea.Batch(a.fetchPage(true), a.countRows())
+	}
+	return a.fetchPage(true)
+}
+
+// searchPlaceholder describes where a search will run
+func searchPlaceholder(server bool) string {
+	if server {
+		return "Search on server (column:value for one column, ctrl+t for local)..."
+	}
+	return "Type to search table (ctrl+t for server search)..."
+}
+
+// searchFilter turns a search query into a server-side filter
+func (a *App) searchFilter(query string) *db.SearchFilter {
+	if query == "" {
+		return nil
+	}
+	if column, pattern, ok := splitColumnQuery(query, a.model.ColumnNames); ok {
+		return &db.SearchFilter{Columns: []string{a.model.ColumnNames[column]}, Pattern: pattern}
+	}
+	return &db.SearchFilter{Columns: a.model.ColumnNames, Pattern: query}
+}
+
+// splitColumnQuery recognizes queries of the form column:value and returns the
+// index of the named column and the value to look for
+func splitColumnQuery(query string, columns []string) (int, string, bool) {
+	i := strings.Index(query, ":")
+	if i <= 0 {
+		return -1, query, false
+	}
+	for c, column := range columns {
+		if strings.EqualFold(column, query[:i]) {
+			return c, query[i+1:], true
+		}
+	}
+	return -1, query, false
+}
+
+// Apply search filter to table data
+func (a *App) applySearchFilter() {
+	if a.model.SearchQuery == "" || a.model.Paging.Filter != "" {
+		// Server-side filtered rows need no further filtering
+		a.model.FilteredData = a.model.Data
+	} else {
+		a.model.FilteredData = a.filterRows(a.model.Data)
 	}
 
 	// Recreate the table with filtered data
 	if len(a.model.ColumnNames) > 0 && len(a.model.FilteredData) > 0 {
-		a.model.TableData = ui.CreateTableData(a.model.ColumnNames, a.model.FilteredData, a.model.HorizontalScrollOffset)
+		a.rebuildGrid()
+		a.model.TableData.SetCursor(0)
+	}
+}
+
+// filterRows returns the rows with any cell matching the search query, or
+// only the named column for column:value queries
+func (a *App) filterRows(rows [][]model.Cell) [][]model.Cell {
+	column, pattern, byColumn := splitColumnQuery(a.model.SearchQuery, a.model.ColumnNames)
+	searchLower := strings.ToLower(pattern)
+	var filtered [][]model.Cell
+
+	// Search through all rows and all columns; NULL never matches, as on the server
+	for _, row := range rows {
+		for i, cell := range row {
+			if cell.Null || (byColumn && i != column) {
+				continue
+			}
+			if strings.Contains(strings.ToLower(cell.Value), searchLower) {
+				filtered = append(filtered, row)
+				break
+			}
+		}
 	}
+	return filtered
 }
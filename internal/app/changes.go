@@ -0,0 +1,314 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/db"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+	"github.com/ddoemonn/go-dot-dot/internal/utils"
+)
+
+// changesAppliedMsg reports the result of committing the pending changes
+type changesAppliedMsg struct {
+	committed int // Number of changes committed, from the start of the changeset
+	affected  int64
+	err       error
+}
+
+// toggleStaging switches between applying edits right away and collecting
+// them into a changeset that is committed in one transaction
+func (a *App) toggleStaging() {
+	if a.model.Staging && len(a.changes) > 0 {
+		a.model.StatusMessage = fmt.Sprintf("Commit or roll back the %d pending %s first (c to review)",
+			len(a.changes), plural(int64(len(a.changes)), "change", "changes"))
+		return
+	}
+	a.model.Staging = !a.model.Staging
+	if a.model.Staging {
+		a.model.StatusMessage = "Staging changes: edits, inserts and deletes are kept until you commit them (c to review)"
+	} else {
+		a.model.StatusMessage = "Changes are applied right away"
+	}
+}
+
+// errCommitting is returned by checkCommitting while a commit is running
+var errCommitting = errors.New("wait for the commit to finish")
+
+// checkCommitting returns an error while the changeset is being committed,
+// when nothing may be staged or dropped until the outcome is known
+func (a *App) checkCommitting() error {
+	if a.committing {
+		return errCommitting
+	}
+	return nil
+}
+
+// statusError returns an error from checkCommitting or checkEditable as the
+// status line shows it. The others start with a relation name, which keeps
+// its case.
+func statusError(err error) string {
+	text := err.Error()
+	if errors.Is(err, errCommitting) {
+		text = strings.ToUpper(text[:1]) + text[1:]
+	}
+	return text
+}
+
+// loadedRow returns a row in the form a change compares it with on commit
+func loadedRow(row []model.Cell) []*string {
+	values := make([]*string, len(row))
+	for i, cell := range row {
+		if !cell.Null {
+			text := cell.Text()
+			values[i] = &text
+		}
+	}
+	return values
+}
+
+// stagedChange returns the index of the pending update or delete of a row
+// of the selected relation, or -1
+func (a *App) stagedChange(row []model.Cell) int {
+	if len(a.changes) == 0 {
+		return -1
+	}
+	id := a.rowKey(row).String()
+	for i, change := range a.changes {
+		if change.Kind != db.ChangeInsert && change.Table == a.model.SelectedTable && change.Key.String() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// stageUpdate adds an edited cell to the changeset, merging it with an
+// earlier update of the same row
+func (a *App) stageUpdate(row []model.Cell, column int, value *string) {
+	name := a.model.ColumnNames[column]
+	if i := a.stagedChange(row); i >= 0 {
+		a.changes[i].Set(name, value)
+	} else {
+		change := db.Change{
+			Kind:          db.ChangeUpdate,
+			Table:         a.model.SelectedTable,
+			Key:           a.rowKey(row),
+			LoadedColumns: a.model.ColumnNames,
+			Loaded:        loadedRow(row),
+		}
+		change.Set(name, value)
+		a.changes = append(a.changes, change)
+	}
+	a.model.StatusMessage = fmt.Sprintf("Staged update of %s", name)
+	a.syncChanges()
+}
+
+// stageInsert adds a new row to the changeset
+func (a *App) stageInsert(columns []string, values []*string) {
+	a.changes = append(a.changes, db.Change{
+		Kind:    db.ChangeInsert,
+		Table:   a.model.SelectedTable,
+		Columns: columns,
+		Values:  values,
+	})
+	a.model.Focused = model.FocusTableData
+	a.model.StatusMessage = "Staged insert into " + a.model.SelectedTable.QualifiedName()
+	a.syncChanges()
+}
+
+// stageDeletes adds deleted rows to the changeset. A delete replaces any
+// pending update of the same row.
+func (a *App) stageDeletes(rows [][]model.Cell) {
+	for _, row := range rows {
+		if i := a.stagedChange(row); i >= 0 {
+			a.changes[i].Kind = db.ChangeDelete
+			a.changes[i].Columns = nil
+			a.changes[i].Values = nil
+			continue
+		}
+		a.changes = append(a.changes, db.Change{
+			Kind:          db.ChangeDelete,
+			Table:         a.model.SelectedTable,
+			Key:           a.rowKey(row),
+			LoadedColumns: a.model.ColumnNames,
+			Loaded:        loadedRow(row),
+		})
+	}
+	a.marked = nil
+	a.model.StatusMessage = fmt.Sprintf("Staged delete of %d %s", len(rows), plural(int64(len(rows)), "row", "rows"))
+	a.syncChanges()
+}
+
+// syncChanges shows the changeset in the review pane and the data grid
+func (a *App) syncChanges() {
+	a.model.PendingSQL = make([]string, len(a.changes))
+	for i, change := range a.changes {
+		a.model.PendingSQL[i] = change.SQL()
+	}
+	if a.model.ChangesCursor >= len(a.changes) {
+		a.model.ChangesCursor = utils.Max(len(a.changes)-1, 0)
+	}
+	a.refreshRows()
+}
+
+// openChanges shows the pending changes for review
+func (a *App) openChanges() {
+	if len(a.changes) == 0 {
+		a.model.StatusMessage = "No pending changes"
+		return
+	}
+	a.model.ChangesError = ""
+	a.model.Focused = model.FocusChanges
+}
+
+// closeChanges returns from the review pane to the data or the table list
+func (a *App) closeChanges() {
+	if a.model.SelectedTable.Name != "" {
+		a.model.Focused = model.FocusTableData
+	} else {
+		a.model.Focused = model.FocusTableList
+	}
+}
+
+// updateChanges handles a key press in the review pane
+func (a *App) updateChanges(msg tea.KeyMsg, confirmDiscard bool) tea.Cmd {
+	switch {
+	case key.Matches(msg, a.keys.Up):
+		if a.model.ChangesCursor > 0 {
+			a.model.ChangesCursor--
+		}
+	case key.Matches(msg, a.keys.Down):
+		if a.model.ChangesCursor < len(a.changes)-1 {
+			a.model.ChangesCursor++
+		}
+	case key.Matches(msg, a.keys.Delete, a.keys.Rollback) && a.committing:
+		a.model.StatusMessage = statusError(a.checkCommitting())
+	case key.Matches(msg, a.keys.Delete):
+		// Drop the change under the cursor from the changeset
+		if len(a.changes) > 0 {
+			a.changes = append(a.changes[:a.model.ChangesCursor], a.changes[a.model.ChangesCursor+1:]...)
+			a.model.ChangesError = ""
+			a.syncChanges()
+		}
+		if len(a.changes) == 0 {
+			a.closeChanges()
+		}
+	case key.Matches(msg, a.keys.Rollback):
+		if !confirmDiscard {
+			a.confirmDiscard = true
+			a.model.StatusMessage = fmt.Sprintf("Press x again to discard all %d pending %s",
+				len(a.changes), plural(int64(len(a.changes)), "change", "changes"))
+			return nil
+		}
+		a.model.StatusMessage = fmt.Sprintf("Rolled back %d %s", len(a.changes), plural(int64(len(a.changes)), "change", "changes"))
+		a.changes = nil
+		a.syncChanges()
+		a.closeChanges()
+	case key.Matches(msg, a.keys.RunQuery):
+		return a.commitChanges()
+	}
+	return nil
+}
+
+// commitChanges applies the changeset in one transaction. Staging is held
+// off until it is done, so the changeset stays the one being committed.
+func (a *App) commitChanges() tea.Cmd {
+	if len(a.changes) == 0 || a.committing {
+		return nil
+	}
+	a.model.ChangesError = ""
+	database := a.db
+	changes := append([]db.Change(nil), a.changes...)
+	cmd := a.startWrite(fmt.Sprintf("Committing %d %s", len(changes), plural(int64(len(changes)), "change", "changes")), func(ctx context.Context) tea.Msg {
+		affected, err := database.ApplyChanges(ctx, changes)
+		return changesAppliedMsg{committed: len(changes), affected: affected, err: err}
+	})
+	a.committing = cmd != nil
+	return cmd
+}
+
+// applyCommit shows the outcome of a commit. On failure nothing was
+// applied; the changeset is kept and the failing change selected.
+func (a *App) applyCommit(msg changesAppliedMsg) tea.Cmd {
+	a.committing = false
+	if msg.err != nil {
+		var changeErr *db.ChangeError
+		if errors.As(msg.err, &changeErr) && changeErr.Index < len(a.changes) {
+			a.model.ChangesCursor = changeErr.Index
+			a.model.ChangesError = fmt.Sprintf("Change %d failed, nothing was applied: %s", changeErr.Index+1, changeError(changeErr.Err))
+		} else {
+			a.model.ChangesError = "Commit failed, nothing was applied: " + changeError(msg.err)
+		}
+		a.model.Focused = model.FocusChanges
+		return nil
+	}
+
+	a.model.StatusMessage = fmt.Sprintf("Committed %d %s, %d %s changed",
+		msg.committed, plural(int64(msg.committed), "change", "changes"), msg.affected, plural(msg.affected, "row", "rows"))
+	a.changes = append([]db.Change(nil), a.changes[utils.Min(msg.committed, len(a.changes)):]...)
+	if len(a.changes) == 0 {
+		a.changes = nil
+	}
+	a.syncChanges()
+	if a.model.Focused == model.FocusChanges && len(a.changes) == 0 {
+		a.closeChanges()
+	}
+	if a.model.SelectedTable.Name == "" {
+		return nil
+	}
+	return a.fetchPage(true)
+}
+
+// markChanges highlights the pending changes to the selected relation in
+// the grid rows: new values of updated cells, deleted rows, and inserted rows
+// added at the end
+func (a *App) markChanges(rows []table.Row) []table.Row {
+	if len(a.changes) == 0 {
+		return rows
+	}
+	for i, row := range a.model.FilteredData {
+		c := a.stagedChange(row)
+		if c < 0 {
+			continue
+		}
+		change := a.changes[c]
+		if change.Kind == db.ChangeDelete {
+			ui.MarkRow(rows[i], a.model.ColumnCursor, ui.DeleteMarker)
+			continue
+		}
+		for j, name := range a.model.ColumnNames {
+			if value, ok := change.Value(name); ok && j < len(rows[i]) {
+				rows[i][j] = ui.ChangedMarker + ui.CellText(stagedCell(value))
+			}
+		}
+	}
+	for _, change := range a.changes {
+		if change.Kind != db.ChangeInsert || change.Table != a.model.SelectedTable {
+			continue
+		}
+		row := make(table.Row, len(a.model.ColumnNames))
+		for j, name := range a.model.ColumnNames {
+			if value, ok := change.Value(name); ok {
+				row[j] = ui.CellText(stagedCell(value))
+			}
+		}
+		ui.MarkRow(row, a.model.ColumnCursor, ui.InsertMarker)
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// stagedCell returns the cell for a staged value
+func stagedCell(value *string) model.Cell {
+	if value == nil {
+		return model.NullCell()
+	}
+	return model.Cell{Value: *value}
+}
@@ -0,0 +1,314 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/config"
+	"github.com/ddoemonn/go-dot-dot/internal/db"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+// slotKind tells apart the kinds of background work. Each kind has a slot of
+// its own, so starting work of one kind never cancels work of another.
+type slotKind int
+
+const (
+	slotQuery  slotKind = iota // Queries the user asked for
+	slotPage                   // Page fetches of the selected relation
+	slotCount                  // Row count of the selected relation
+	slotWrite                  // Statements that change data
+	slotExport                 // Exports of whole relations
+	slotKinds
+)
+
+// querySlot tracks the background work of one kind in flight
+type querySlot struct {
+	id          int
+	cancel      context.CancelFunc
+	description string // Shown next to the spinner; empty runs the work quietly
+}
+
+// queryDoneMsg wraps the result of background work so that only the work a
+// slot is waiting for frees it
+type queryDoneMsg struct {
+	kind slotKind
+	id   int
+	msg  tea.Msg
+}
+
+// connectedMsg carries a new connection opened from the connection picker
+type connectedMsg struct {
+	profile  config.Profile
+	database *db.Database
+	err      error
+}
+
+// tablesLoadedMsg carries the relations shown in the table list
+type tablesLoadedMsg struct {
+	tables []model.TableItem
+	err    error
+}
+
+// tableOpenedMsg carries the first page of a relation opened in the data pane
+type tableOpenedMsg struct {
+	table       model.TableItem
+	match       *model.RowMatch
+	keyColumns  []string
+	foreignKeys []model.ForeignKey
+	referencing []model.ForeignKey
+	trail       []navEntry // Relations followed to reach this one
+	page        *db.Page
+	err         error
+}
+
+// rowsCountedMsg carries the row count of a relation, fetched after its
+// first page
+type rowsCountedMsg struct {
+	table model.TableItem
+	total int64
+	exact bool
+	err   error
+}
+
+// pageLoadedMsg carries the result of a background page fetch. A reset page
+// replaces the loaded rows instead of being appended to them.
+type pageLoadedMsg struct {
+	table      model.TableItem
+	generation int
+	reset      bool
+	restore    []string // Keys of the rows to put the cursor on, by preference
+	page       *db.Page
+	err        error
+}
+
+// viewRefreshedMsg reports the end of a REFRESH MATERIALIZED VIEW
+type viewRefreshedMsg struct {
+	table model.TableItem
+	err   error
+}
+
+// startQuery runs fn in the background under a cancellable context and shows
+// the spinner with the given description until it finishes. Only one query is
+// in flight at a time: starting a new one cancels the previous. Page fetches,
+// row counts and writes have slots of their own and are left running.
+func (a *App) startQuery(description string, fn func(ctx context.Context) tea.Msg) tea.Cmd {
+	return a.run(slotQuery, description, fn)
+}
+
+// startWrite runs a statement that changes data in the background, like
+// startQuery but in a slot of its own: starting a query doesn't cancel it,
+// only the cancel key does. A second write is refused while one is in flight.
+func (a *App) startWrite(description string, fn func(ctx context.Context) tea.Msg) tea.Cmd {
+	if a.busy(slotWrite) {
+		return nil
+	}
+	return a.run(slotWrite, description, fn)
+}
+
+// run starts fn in the background in the slot for its kind, cancelling the
+// work already there
+func (a *App) run(kind slotKind, description string, fn func(ctx context.Context) tea.Msg) tea.Cmd {
+	slot := &a.slots[kind]
+	if slot.cancel != nil {
+		slot.cancel()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	slot.id++
+	slot.cancel = cancel
+	slot.description = description
+
+	// The spinner ticks for as long as anything is loading; start it only
+	// when it isn't ticking already
+	idle := a.model.Loading == ""
+	a.showLoading()
+	id := slot.id
+	cmd := func() tea.Msg {
+		defer cancel()
+		return queryDoneMsg{kind: kind, id: id, msg: fn(ctx)}
+	}
+	if idle && a.model.Loading != "" {
+		return tea.Batch(a.model.Spinner.Tick, cmd)
+	}
+	return cmd
+}
+
+// finish frees the slot of background work that is done, unless other work
+// has taken the slot since. It reports whether the work was still the
+// slot's, and so whether its result applies.
+func (a *App) finish(msg queryDoneMsg) bool {
+	slot := &a.slots[msg.kind]
+	if msg.id != slot.id {
+		return false
+	}
+	*slot = querySlot{id: slot.id}
+	a.showLoading()
+	return true
+}
+
+// showLoading shows the description of the background work that matters
+// most next to the spinner, or hides the spinner when there is none
+func (a *App) showLoading() {
+	a.model.Loading = ""
+	for _, kind := range []slotKind{slotWrite, slotExport, slotQuery, slotPage} {
+		if slot := a.slots[kind]; slot.cancel != nil && slot.description != "" {
+			a.model.Loading = slot.description
+			return
+		}
+	}
+}
+
+// busy reports whether work of a kind is in flight, asking the user to wait
+// for it when it is
+func (a *App) busy(kind slotKind) bool {
+	slot := a.slots[kind]
+	if slot.cancel == nil {
+		return false
+	}
+	a.model.StatusMessage = "Wait for " + strings.ToLower(slot.description) + " to finish"
+	return true
+}
+
+// cancelSlot cancels the work of a kind in flight, reporting whether there
+// was any
+func (a *App) cancelSlot(kind slotKind) bool {
+	if a.slots[kind].cancel == nil {
+		return false
+	}
+	a.slots[kind].cancel()
+	return true
+}
+
+// cancelQueries cancels all background work in flight, reporting whether
+// there was any
+func (a *App) cancelQueries() bool {
+	cancelled := false
+	for kind := slotKind(0); kind < slotKinds; kind++ {
+		cancelled = a.cancelSlot(kind) || cancelled
+	}
+	return cancelled
+}
+
+// connectProfile opens a connection for a saved profile in the background
+func (a *App) connectProfile(cfg *config.Config) tea.Cmd {
+	profile := config.Profile{Name: cfg.Profile, DB: cfg.DB}
+	profile.DB.ReadOnly = profile.DB.ReadOnly || a.forceReadOnly
+	return a.startQuery("Connecting to "+profile.Name, func(ctx context.Context) tea.Msg {
+		database, err := db.Connect(ctx, &profile.DB)
+		return connectedMsg{profile: profile, database: database, err: err}
+	})
+}
+
+// loadTables fetches the relations for the table list
+func (a *App) loadTables() tea.Cmd {
+	database := a.db
+	return a.startQuery("Loading relations", func(ctx context.Context) tea.Msg {
+		tables, err := database.FetchTables(ctx)
+		return tablesLoadedMsg{tables: tables, err: err}
+	})
+}
+
+// openTable loads the key, foreign keys, first page and row count of a
+// relation
+func (a *App) openTable(table model.TableItem) tea.Cmd {
+	return a.openTableAt(table, nil, nil)
+}
+
+// openTableAt opens a relation showing only the rows a match selects, or
+// all of them when match is nil. The trail replaces the back stack once the
+// relation is open.
+func (a *App) openTableAt(table model.TableItem, match *model.RowMatch, trail []navEntry) tea.Cmd {
+	// The rows and count of the relation shown are of no use any more
+	a.cancelSlot(slotPage)
+	a.cancelSlot(slotCount)
+	database := a.db
+	return a.startQuery("Opening "+table.QualifiedName(), func(ctx context.Context) tea.Msg {
+		msg := tableOpenedMsg{table: table, match: match, trail: trail}
+		msg.keyColumns, msg.err = database.FetchRowKey(ctx, table)
+		if msg.err != nil {
+			return msg
+		}
+		msg.foreignKeys, msg.err = database.FetchForeignKeys(ctx, table)
+		if msg.err != nil {
+			return msg
+		}
+		msg.referencing, msg.err = database.FetchReferencingKeys(ctx, table)
+		if msg.err != nil {
+			return msg
+		}
+		req := db.PageRequest{KeyColumns: msg.keyColumns, Match: match, Limit: db.PageSize}
+		msg.page, msg.err = database.FetchTableData(ctx, table, req)
+		return msg
+	})
+}
+
+// countRows counts the rows of the selected relation once its first page is
+// shown, quietly and in a slot of its own so queries and page loads don't
+// cancel it. Opening another relation and the cancel key do. Nothing is
+// counted while a server filter is active, as the total is of the whole
+// relation.
+func (a *App) countRows() tea.Cmd {
+	if a.model.Paging.Filter != "" {
+		return nil
+	}
+	database := a.db
+	table := a.model.SelectedTable
+	return a.run(slotCount, "", func(ctx context.Context) tea.Msg {
+		total, exact, err := database.CountRows(ctx, table)
+		return rowsCountedMsg{table: table, total: total, exact: exact, err: err}
+	})
+}
+
+// fetchPage fetches a page of the selected relation in the background. A
+// reset fetch starts again from the first page.
+func (a *App) fetchPage(reset bool) tea.Cmd {
+	return a.fetchRows(reset, db.PageSize, nil)
+}
+
+// fetchRows fetches up to limit rows of the selected relation in the
+// background, in the page slot so it doesn't cancel other queries. Once a
+// reset fetch is shown the cursor goes to the first row of restore that is
+// among them.
+func (a *App) fetchRows(reset bool, limit int, restore []string) tea.Cmd {
+	a.model.Paging.Loading = true
+	database := a.db
+	table := a.model.SelectedTable
+	req := db.PageRequest{
+		KeyColumns: a.model.Paging.KeyColumns,
+		After:      a.model.Paging.LastKey,
+		Offset:     len(a.model.Data),
+		Limit:      limit,
+		Filter:     a.searchFilter(a.model.Paging.Filter),
+		Match:      a.model.Paging.Match,
+		Sort:       a.model.SortKeys,
+	}
+	if reset {
+		req.After = nil
+		req.Offset = 0
+		a.model.Paging.Generation++
+	}
+	generation := a.model.Paging.Generation
+	return a.run(slotPage, "Loading rows", func(ctx context.Context) tea.Msg {
+		page, err := database.FetchTableData(ctx, table, req)
+		return pageLoadedMsg{table: table, generation: generation, reset: reset, restore: restore, page: page, err: err}
+	})
+}
+
+// refreshView runs REFRESH MATERIALIZED VIEW in the background
+func (a *App) refreshView(table model.TableItem) tea.Cmd {
+	database := a.db
+	return a.startWrite("Refreshing "+table.QualifiedName(), func(ctx context.Context) tea.Msg {
+		return viewRefreshedMsg{table: table, err: database.RefreshMaterializedView(ctx, table)}
+	})
+}
+
+// queryError describes a failed query, treating cancellation as deliberate
+func queryError(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return "query cancelled"
+	}
+	return err.Error()
+}
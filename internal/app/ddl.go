@@ -0,0 +1,253 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/atotto/clipboard"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+)
+
+// ddlLoadedMsg carries generated DDL for the DDL pane
+type ddlLoadedMsg struct {
+	title string
+	name  string // Suggested file name
+	ddl   string
+	err   error
+}
+
+// showDDL generates the DDL of what is selected: the relation under the
+// cursor of the table list or shown in the table view, or the index or
+// trigger under the cursor of their tabs
+func (a *App) showDDL() tea.Cmd {
+	database := a.db
+	var table model.TableItem
+	if a.model.Focused == model.FocusTableList {
+		i, ok := a.model.TableList.SelectedItem().(model.TableItem)
+		if !ok {
+			return nil
+		}
+		table = i
+	} else {
+		table = a.model.SelectedTable
+		cursor := a.model.TabTable.Cursor()
+		switch {
+		case a.model.TableTab == model.TabIndexes && cursor >= 0 && cursor < len(a.tabDetails.indexes):
+			index := a.tabDetails.indexes[cursor]
+			a.openDDL(ddlLoadedMsg{title: index.Name, name: index.Name, ddl: index.Definition + ";\n"})
+			return nil
+		case a.model.TableTab == model.TabTriggers && cursor >= 0 && cursor < len(a.tabDetails.triggers):
+			trigger := a.tabDetails.triggers[cursor]
+			return a.startQuery("Generating DDL for "+trigger.Name, func(ctx context.Context) tea.Msg {
+				msg := ddlLoadedMsg{title: trigger.Name, name: trigger.Name}
+				var function string
+				function, msg.err = database.FetchFunctionDDL(ctx, trigger.Function)
+				msg.ddl = trigger.Definition + ";\n\n" + function
+				return msg
+			})
+		}
+	}
+
+	return a.startQuery("Generating DDL for "+table.QualifiedName(), func(ctx context.Context) tea.Msg {
+		msg := ddlLoadedMsg{title: table.QualifiedName(), name: table.QualifiedName()}
+		msg.ddl, msg.err = database.FetchDDL(ctx, table)
+		return msg
+	})
+}
+
+// functionsLoadedMsg carries the functions for the functions picker
+type functionsLoadedMsg struct {
+	functions []model.FunctionItem
+	err       error
+}
+
+// loadFunctions fetches the functions and procedures of the database to pick
+// one to show the DDL of
+func (a *App) loadFunctions() tea.Cmd {
+	database := a.db
+	return a.startQuery("Loading functions", func(ctx context.Context) tea.Msg {
+		functions, err := database.FetchFunctions(ctx)
+		return functionsLoadedMsg{functions: functions, err: err}
+	})
+}
+
+// showFunctions opens the functions picker
+func (a *App) showFunctions(msg functionsLoadedMsg) {
+	switch {
+	case msg.err != nil:
+		a.model.StatusMessage = "Failed to load functions: " + queryError(msg.err)
+		return
+	case len(msg.functions) == 0:
+		a.model.StatusMessage = "No functions or procedures outside the system schemas"
+		return
+	}
+	a.model.FunctionList.ResetFilter()
+	a.model.FunctionList.SetItems(ui.CreateFunctionItems(msg.functions))
+	a.model.FunctionList.ResetSelected()
+	a.model.Focused = model.FocusFunctions
+}
+
+// functionsFiltering reports whether the functions picker's filter input
+// has the keyboard
+func (a *App) functionsFiltering() bool {
+	return a.model.Focused == model.FocusFunctions && a.model.FunctionList.FilterState() == list.Filtering
+}
+
+// leaveFunctions handles Esc in the functions picker: it clears an applied
+// filter first, then returns to the table list
+func (a *App) leaveFunctions() {
+	if a.model.FunctionList.FilterState() == list.FilterApplied {
+		a.model.FunctionList.ResetFilter()
+		return
+	}
+	a.model.Focused = model.FocusTableList
+}
+
+// updateFunctions handles a key press in the functions picker
+func (a *App) updateFunctions(msg tea.KeyMsg) tea.Cmd {
+	if key.Matches(msg, a.keys.Select) {
+		function, ok := a.model.FunctionList.SelectedItem().(model.FunctionItem)
+		if !ok {
+			return nil
+		}
+		database := a.db
+		return a.startQuery("Generating DDL for "+function.Name, func(ctx context.Context) tea.Msg {
+			msg := ddlLoadedMsg{title: function.Schema + "." + function.Name, name: function.Schema + "." + function.Name}
+			msg.ddl, msg.err = database.FetchFunctionDDL(ctx, function.Signature)
+			return msg
+		})
+	}
+	var cmd tea.Cmd
+	a.model.FunctionList, cmd = a.model.FunctionList.Update(msg)
+	return cmd
+}
+
+// openDDL shows generated DDL in its pane
+func (a *App) openDDL(msg ddlLoadedMsg) {
+	if msg.err != nil {
+		a.model.StatusMessage = fmt.Sprintf("Failed to generate DDL for %s: %s", msg.title, queryError(msg.err))
+		return
+	}
+	a.ddl = msg
+	a.model.DDLTitle = msg.title
+	a.model.DDLView = ui.CreateDDLView(msg.ddl, a.model.Width-12, a.model.Height-14)
+	if a.model.Focused != model.FocusDDL {
+		a.model.DDLReturn = a.model.Focused
+	}
+	a.model.Focused = model.FocusDDL
+}
+
+// updateDDL handles a key press in the DDL pane
+func (a *App) updateDDL(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, a.keys.Copy):
+		if err := clipboard.WriteAll(a.ddl.ddl); err != nil {
+			a.model.StatusMessage = "Failed to copy to the clipboard: " + err.Error()
+		} else {
+			a.model.StatusMessage = "Copied the DDL of " + a.ddl.title + " to the clipboard"
+		}
+		return nil
+	case key.Matches(msg, a.keys.WriteFile):
+		ddl := a.ddl
+		a.promptSave("DDL", ddl.name+".sql", func(a *App, path string) tea.Cmd {
+			err := replaceFile(path, func(f *os.File) error {
+				_, err := f.WriteString(ddl.ddl)
+				return err
+			})
+			if err != nil {
+				a.model.StatusMessage = "Failed to write the DDL: " + err.Error()
+			} else {
+				a.model.StatusMessage = "Wrote the DDL of " + ddl.title + " to " + path
+			}
+			return nil
+		})
+		return nil
+	}
+
+	var cmd tea.Cmd
+	a.model.DDLView, cmd = a.model.DDLView.Update(msg)
+	return cmd
+}
+
+// replaceFile writes a file through write. The content goes to a temporary
+// file next to it that replaces the file once complete, so a failed write
+// leaves any existing file as it was.
+func replaceFile(path string, write func(f *os.File) error) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// promptSave asks for the file to save something to, suggesting a name, and
+// hands the path to save once it is entered. Update works on a copy of the
+// App, so save is given the one current by then.
+func (a *App) promptSave(what, name string, save func(a *App, path string) tea.Cmd) {
+	a.model.SavePrompt = what
+	a.model.SaveInput = ui.CreateEditInput(name)
+	a.pendingSave = save
+}
+
+// updateSavePrompt handles a key press while the file name prompt is open.
+// Replacing an existing file takes a second Enter.
+func (a *App) updateSavePrompt(msg tea.KeyMsg, confirmOverwrite bool) tea.Cmd {
+	switch msg.String() {
+	case "esc":
+		a.model.SavePrompt = ""
+		a.pendingSave = nil
+		return nil
+	case "enter":
+		path := expandPath(strings.TrimSpace(a.model.SaveInput.Value()))
+		if path == "" {
+			a.model.StatusMessage = "Enter a file name"
+			return nil
+		}
+		if _, err := os.Stat(path); err == nil && !confirmOverwrite {
+			a.confirmOverwrite = true
+			a.model.StatusMessage = path + " exists; press Enter again to replace it"
+			return nil
+		}
+		save := a.pendingSave
+		a.model.SavePrompt = ""
+		a.pendingSave = nil
+		return save(a, path)
+	}
+
+	var cmd tea.Cmd
+	a.model.SaveInput, cmd = a.model.SaveInput.Update(msg)
+	return cmd
+}
+
+// expandPath resolves a leading ~ to the home directory
+func expandPath(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,523 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/ddoemonn/go-dot-dot/internal/db"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+	"github.com/ddoemonn/go-dot-dot/internal/utils"
+)
+
+// cellUpdatedMsg reports the result of saving an edited cell
+type cellUpdatedMsg struct {
+	table      model.TableItem
+	generation int
+	row        []model.Cell
+	column     int
+	cell       model.Cell
+	affected   int64
+	err        error
+}
+
+// checkEditable explains why the rows of the selected relation can't be
+// changed, or returns nil when they can
+func (a *App) checkEditable() error {
+	if err := a.checkCommitting(); err != nil {
+		return err
+	}
+	table := a.model.SelectedTable
+	switch table.Kind {
+	case model.KindTable, model.KindPartitionedTable, model.KindForeignTable:
+	default:
+		return fmt.Errorf("%s is a %s; only tables can be edited", table.QualifiedName(), table.KindLabel())
+	}
+	if len(a.model.Paging.KeyColumns) == 0 {
+		return fmt.Errorf("%s has no primary key or unique key to identify rows by, so it can't be edited", table.QualifiedName())
+	}
+	return nil
+}
+
+// rowKey returns the key identifying a loaded row of the selected relation
+func (a *App) rowKey(row []model.Cell) db.RowKey {
+	key := db.RowKey{Columns: a.model.Paging.KeyColumns}
+	for _, column := range key.Columns {
+		for i, name := range a.model.ColumnNames {
+			if name == column {
+				key.Values = append(key.Values, row[i].Text())
+				break
+			}
+		}
+	}
+	return key
+}
+
+// selectedCell returns the row and column the edit key applies to: the
+// field under the cursor in the detail view, and the column under the column
+// cursor of the selected row in the data view
+func (a *App) selectedCell() (row int, column int, ok bool) {
+	switch a.model.Focused {
+	case model.FocusTableData:
+		row = a.model.TableData.Cursor()
+		column = a.model.ColumnCursor
+	case model.FocusDetail:
+		if a.model.DetailReturn != model.FocusTableData {
+			return 0, 0, false
+		}
+		fields := ui.DetailFields(a.model.SelectedRowData)
+		if a.model.DetailCursor >= len(fields) {
+			return 0, 0, false
+		}
+		row = a.model.SelectedRow
+		column = -1
+		for i, name := range a.model.ColumnNames {
+			if name == fields[a.model.DetailCursor] {
+				column = i
+			}
+		}
+	default:
+		return 0, 0, false
+	}
+	ok = row >= 0 && row < len(a.model.FilteredData) && column >= 0 && column < len(a.model.ColumnNames)
+	return row, column, ok
+}
+
+// startEdit opens the cell editor on the selected cell
+func (a *App) startEdit() tea.Cmd {
+	if a.model.Focused == model.FocusDetail && a.model.DetailReturn != model.FocusTableData {
+		a.model.StatusMessage = "Query results can't be edited; open the table instead"
+		return nil
+	}
+	if err := a.checkEditable(); err != nil {
+		a.model.StatusMessage = statusError(err)
+		return nil
+	}
+	row, column, ok := a.selectedCell()
+	if !ok {
+		return nil
+	}
+
+	a.editRow = a.model.FilteredData[row]
+	a.editColumn = column
+	a.model.EditColumn = a.model.ColumnNames[column]
+	cell := a.editRow[column]
+	if c := a.stagedChange(a.editRow); c >= 0 {
+		if a.changes[c].Kind == db.ChangeDelete {
+			a.model.StatusMessage = "This row is staged for deletion"
+			return nil
+		}
+		// Start from the value already staged for the cell
+		if staged, ok := a.changes[c].Value(a.model.EditColumn); ok {
+			cell = stagedCell(staged)
+		}
+	}
+	value := ""
+	if !cell.Null {
+		value = cell.Text()
+	}
+	a.model.EditInput = ui.CreateEditInput(value)
+	a.model.Editing = true
+	return nil
+}
+
+// updateEdit handles a key press in the cell editor
+func (a *App) updateEdit(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		a.cancelQueries()
+		return tea.Quit
+	case "esc":
+		a.model.Editing = false
+		return nil
+	case "enter":
+		value := a.model.EditInput.Value()
+		return a.saveEdit(&value)
+	case "ctrl+n":
+		return a.saveEdit(nil)
+	}
+
+	var cmd tea.Cmd
+	a.model.EditInput, cmd = a.model.EditInput.Update(msg)
+	return cmd
+}
+
+// saveEdit updates the edited cell in the database. A nil value sets NULL.
+// While another write is in flight the editor stays open with the value.
+func (a *App) saveEdit(value *string) tea.Cmd {
+	if a.model.Staging {
+		a.model.Editing = false
+		a.stageUpdate(a.editRow, a.editColumn, value)
+		return nil
+	}
+	if a.busy(slotWrite) {
+		return nil
+	}
+	a.model.Editing = false
+	database := a.db
+	table := a.model.SelectedTable
+	generation := a.model.Paging.Generation
+	row, column := a.editRow, a.editColumn
+	key := a.rowKey(row)
+	name := a.model.ColumnNames[column]
+	return a.startWrite("Updating "+table.QualifiedName(), func(ctx context.Context) tea.Msg {
+		cell, affected, err := database.UpdateCell(ctx, table, key, name, value)
+		return cellUpdatedMsg{table: table, generation: generation, row: row, column: column, cell: cell, affected: affected, err: err}
+	})
+}
+
+// applyCellUpdate shows the outcome of saving a cell, putting the stored
+// value into the loaded row
+func (a *App) applyCellUpdate(msg cellUpdatedMsg) {
+	if msg.err != nil {
+		a.model.StatusMessage = "Update failed: " + changeError(msg.err)
+		return
+	}
+	if msg.affected == 0 {
+		a.model.StatusMessage = "No row updated: it was deleted or its key changed since it was loaded"
+		return
+	}
+	a.model.StatusMessage = fmt.Sprintf("Updated %d %s in %s", msg.affected, plural(msg.affected, "row", "rows"), msg.table.QualifiedName())
+	if msg.table != a.model.SelectedTable || msg.generation != a.model.Paging.Generation {
+		return
+	}
+
+	// The row is shared with the loaded data, so this updates it everywhere
+	msg.row[msg.column] = msg.cell
+	a.refreshRows()
+	if a.model.Focused == model.FocusDetail {
+		a.model.SelectedRowData = rowData(a.model.ColumnNames, msg.row)
+	}
+}
+
+// changeError describes a failed data change in one line, adding the
+// server's detail and hint to its message
+func changeError(err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return queryError(err)
+	}
+	text := pgErr.Message
+	if pgErr.Detail != "" {
+		text += " (" + pgErr.Detail + ")"
+	}
+	if pgErr.Hint != "" {
+		text += " Hint: " + pgErr.Hint
+	}
+	return text
+}
+
+// plural picks the singular or plural form of a word for a count
+func plural(n int64, singular, many string) string {
+	if n == 1 {
+		return singular
+	}
+	return many
+}
+
+// columnsLoadedMsg delivers the columns for the insert form
+type columnsLoadedMsg struct {
+	table   model.TableItem
+	columns []model.Column
+	err     error
+}
+
+// rowsChangedMsg reports the result of inserting or deleting rows
+type rowsChangedMsg struct {
+	table    model.TableItem
+	inserted bool // An insert rather than a delete
+	affected int64
+	err      error
+}
+
+// openInsertForm loads the columns of the selected table for the insert form
+func (a *App) openInsertForm() tea.Cmd {
+	if err := a.checkCommitting(); err != nil {
+		a.model.StatusMessage = statusError(err)
+		return nil
+	}
+	table := a.model.SelectedTable
+	switch table.Kind {
+	case model.KindTable, model.KindPartitionedTable, model.KindForeignTable:
+	default:
+		a.model.StatusMessage = fmt.Sprintf("%s is a %s; rows can only be inserted into tables", table.QualifiedName(), table.KindLabel())
+		return nil
+	}
+	database := a.db
+	return a.startQuery("Loading columns", func(ctx context.Context) tea.Msg {
+		columns, err := database.FetchColumns(ctx, table)
+		return columnsLoadedMsg{table: table, columns: columns, err: err}
+	})
+}
+
+// showInsertForm opens the insert form on the writable columns
+func (a *App) showInsertForm(msg columnsLoadedMsg) tea.Cmd {
+	if msg.err != nil {
+		a.model.StatusMessage = "Failed to load columns: " + queryError(msg.err)
+		return nil
+	}
+	if msg.table != a.model.SelectedTable || a.model.Focused != model.FocusTableData {
+		return nil
+	}
+
+	a.model.InsertColumns = nil
+	for _, column := range msg.columns {
+		if !column.Generated {
+			a.model.InsertColumns = append(a.model.InsertColumns, column)
+		}
+	}
+	a.model.InsertInputs = make([]textinput.Model, len(a.model.InsertColumns))
+	for i, column := range a.model.InsertColumns {
+		a.model.InsertInputs[i] = ui.CreateInsertInput(column)
+	}
+	a.model.InsertNull = make([]bool, len(a.model.InsertColumns))
+	a.model.InsertFocus = 0
+	a.model.InsertError = ""
+	a.model.Focused = model.FocusInsert
+	if len(a.model.InsertInputs) == 0 {
+		return nil
+	}
+	return a.model.InsertInputs[0].Focus()
+}
+
+// updateInsertForm handles a key press in the insert form
+func (a *App) updateInsertForm(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		a.cancelQueries()
+		return tea.Quit
+	case "esc":
+		a.model.Focused = model.FocusTableData
+		return nil
+	case "tab", "down":
+		return a.focusInsertField(a.model.InsertFocus + 1)
+	case "shift+tab", "up":
+		return a.focusInsertField(a.model.InsertFocus - 1)
+	case "enter":
+		if a.model.InsertFocus < len(a.model.InsertInputs)-1 {
+			return a.focusInsertField(a.model.InsertFocus + 1)
+		}
+		return a.insertRow()
+	case "ctrl+n":
+		if len(a.model.InsertNull) > 0 {
+			a.model.InsertNull[a.model.InsertFocus] = !a.model.InsertNull[a.model.InsertFocus]
+		}
+		return nil
+	}
+	if key.Matches(msg, a.keys.RunQuery) {
+		return a.insertRow()
+	}
+	if len(a.model.InsertInputs) == 0 {
+		return nil
+	}
+
+	// Typing into a NULL field gives it a value again
+	a.model.InsertNull[a.model.InsertFocus] = false
+	var cmd tea.Cmd
+	a.model.InsertInputs[a.model.InsertFocus], cmd = a.model.InsertInputs[a.model.InsertFocus].Update(msg)
+	return cmd
+}
+
+// focusInsertField moves the focus to another field of the insert form,
+// wrapping around at either end
+func (a *App) focusInsertField(index int) tea.Cmd {
+	count := len(a.model.InsertInputs)
+	if count == 0 {
+		return nil
+	}
+	a.model.InsertInputs[a.model.InsertFocus].Blur()
+	a.model.InsertFocus = (index + count) % count
+	return a.model.InsertInputs[a.model.InsertFocus].Focus()
+}
+
+// insertRow inserts the values of the insert form. Empty fields are left
+// out, so they take the column default.
+func (a *App) insertRow() tea.Cmd {
+	var columns []string
+	var values []*string
+	for i, input := range a.model.InsertInputs {
+		value := input.Value()
+		switch {
+		case a.model.InsertNull[i]:
+			columns = append(columns, a.model.InsertColumns[i].Name)
+			values = append(values, nil)
+		case value != "":
+			columns = append(columns, a.model.InsertColumns[i].Name)
+			values = append(values, &value)
+		}
+	}
+
+	if a.model.Staging {
+		a.stageInsert(columns, values)
+		return nil
+	}
+
+	a.model.InsertError = ""
+	database := a.db
+	table := a.model.SelectedTable
+	return a.startWrite("Inserting into "+table.QualifiedName(), func(ctx context.Context) tea.Msg {
+		affected, err := database.InsertRow(ctx, table, columns, values)
+		return rowsChangedMsg{table: table, inserted: true, affected: affected, err: err}
+	})
+}
+
+// toggleMark adds the row under the cursor to the selection for deleting,
+// or takes it out again
+func (a *App) toggleMark() {
+	if err := a.checkEditable(); err != nil {
+		a.model.StatusMessage = statusError(err)
+		return
+	}
+	row := a.model.TableData.Cursor()
+	if row < 0 || row >= len(a.model.FilteredData) {
+		return
+	}
+	id := a.rowKey(a.model.FilteredData[row]).String()
+	if a.marked == nil {
+		a.marked = make(map[string][]model.Cell)
+	}
+	if _, ok := a.marked[id]; ok {
+		delete(a.marked, id)
+	} else {
+		a.marked[id] = a.model.FilteredData[row]
+	}
+	a.refreshRows()
+	a.model.TableData.MoveDown(1)
+	if len(a.marked) > 0 {
+		a.model.StatusMessage = fmt.Sprintf("%d %s selected | d deletes them", len(a.marked), plural(int64(len(a.marked)), "row", "rows"))
+	}
+}
+
+// deleteRows deletes the selected rows, or the row under the cursor when
+// none are selected. It asks for a second press first, unless the delete is
+// only staged.
+func (a *App) deleteRows(confirmed bool) tea.Cmd {
+	if err := a.checkEditable(); err != nil {
+		a.model.StatusMessage = statusError(err)
+		return nil
+	}
+	var rows [][]model.Cell
+	for _, row := range a.marked {
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		cursor := a.model.TableData.Cursor()
+		if cursor < 0 || cursor >= len(a.model.FilteredData) {
+			return nil
+		}
+		rows = append(rows, a.model.FilteredData[cursor])
+	}
+
+	if a.model.Staging {
+		// Staged deletes are reviewed before they are committed instead
+		a.stageDeletes(rows)
+		return nil
+	}
+
+	table := a.model.SelectedTable
+	if !confirmed {
+		a.confirmDelete = true
+		a.model.StatusMessage = fmt.Sprintf("Press d again to delete %d %s from %s",
+			len(rows), plural(int64(len(rows)), "row", "rows"), table.QualifiedName())
+		return nil
+	}
+
+	keys := make([]db.RowKey, len(rows))
+	for i, row := range rows {
+		keys[i] = a.rowKey(row)
+	}
+	database := a.db
+	return a.startWrite("Deleting from "+table.QualifiedName(), func(ctx context.Context) tea.Msg {
+		affected, err := database.DeleteRows(ctx, table, keys)
+		return rowsChangedMsg{table: table, affected: affected, err: err}
+	})
+}
+
+// applyRowsChanged shows the outcome of an insert or delete and reloads the
+// rows. A failed insert keeps the form open with the server's error.
+func (a *App) applyRowsChanged(msg rowsChangedMsg) tea.Cmd {
+	if msg.err != nil {
+		if msg.inserted && a.model.Focused == model.FocusInsert {
+			a.model.InsertError = changeError(msg.err)
+		} else {
+			a.model.StatusMessage = "Change failed: " + changeError(msg.err)
+		}
+		return nil
+	}
+
+	rows := plural(msg.affected, "row", "rows")
+	if msg.inserted {
+		a.model.StatusMessage = fmt.Sprintf("Inserted %d %s into %s", msg.affected, rows, msg.table.QualifiedName())
+	} else {
+		a.model.StatusMessage = fmt.Sprintf("Deleted %d %s from %s", msg.affected, rows, msg.table.QualifiedName())
+	}
+	if msg.table != a.model.SelectedTable {
+		return nil
+	}
+	if a.model.Focused == model.FocusInsert {
+		a.model.Focused = model.FocusTableData
+	}
+	a.marked = nil
+	if a.model.Paging.TotalRows >= 0 && !a.model.Paging.Estimated {
+		if msg.inserted {
+			a.model.Paging.TotalRows += msg.affected
+		} else {
+			a.model.Paging.TotalRows -= msg.affected
+		}
+	}
+	return a.reloadRows(int(msg.affected))
+}
+
+// reloadRows reloads as many rows as were loaded after changed rows were
+// inserted or deleted, keeping the cursor on the row it was on, or the
+// nearest one still there: the next rows first, then the ones before it
+func (a *App) reloadRows(changed int) tea.Cmd {
+	var restore []string
+	if cursor := a.model.TableData.Cursor(); cursor >= 0 && cursor < len(a.model.FilteredData) {
+		for i := cursor; i <= cursor+changed && i < len(a.model.FilteredData); i++ {
+			restore = append(restore, a.rowKey(a.model.FilteredData[i]).String())
+		}
+		for i := cursor - 1; i >= cursor-changed && i >= 0; i-- {
+			restore = append(restore, a.rowKey(a.model.FilteredData[i]).String())
+		}
+	}
+	return a.fetchRows(true, utils.Max(len(a.model.Data)+changed, db.PageSize), restore)
+}
+
+// restoreCursor puts the cursor on the first of the rows with the given
+// keys that is loaded
+func (a *App) restoreCursor(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	rows := make(map[string]int, len(a.model.FilteredData))
+	for i, row := range a.model.FilteredData {
+		rows[a.rowKey(row).String()] = i
+	}
+	for _, key := range keys {
+		if i, ok := rows[key]; ok {
+			a.model.TableData.SetCursor(i)
+			return
+		}
+	}
+}
+
+// refreshRows redraws the rows of the data grid, marking the selected ones
+// and the pending changes
+func (a *App) refreshRows() {
+	rows := ui.CreateTableRows(a.model.ColumnNames, a.model.FilteredData)
+	if len(a.marked) > 0 {
+		for i, row := range a.model.FilteredData {
+			if _, ok := a.marked[a.rowKey(row).String()]; ok {
+				ui.MarkRow(rows[i], a.model.ColumnCursor, ui.RowMarker)
+			}
+		}
+	}
+	a.model.TableData.SetRows(a.markChanges(rows))
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/export"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+// exportDoneMsg reports the end of an export
+type exportDoneMsg struct {
+	path string
+	rows int64
+	err  error
+}
+
+// exportView asks for a file to write the rows shown in the grid to: the
+// loaded rows of the table view that pass the search filter, in their sort
+// order and without the hidden columns, or the rows of the query results
+func (a *App) exportView() {
+	columns, rows := a.model.ColumnNames, a.model.FilteredData
+	title := a.model.SelectedTable.QualifiedName()
+	hidden := a.model.HiddenColumns
+	if a.model.Focused == model.FocusQuery {
+		if a.model.QueryResult == nil {
+			return
+		}
+		columns, rows = a.model.QueryResult.Columns, a.model.QueryResult.Rows
+		title = "query"
+		hidden = nil
+	}
+	if len(columns) == 0 {
+		a.model.StatusMessage = "Nothing to export"
+		return
+	}
+
+	// Indexes of the columns that are shown
+	shown := make([]int, 0, len(columns))
+	for i, column := range columns {
+		if !hidden[column] {
+			shown = append(shown, i)
+		}
+	}
+	which := "all columns"
+	if len(shown) < len(columns) {
+		which = fmt.Sprintf("%d of %d columns", len(shown), len(columns))
+	}
+
+	// Copy the cells now, as edits change the loaded rows in place while the
+	// export is written in the background
+	header := pick(columns, shown)
+	cells := make([][]model.Cell, len(rows))
+	for i, row := range rows {
+		cells[i] = pick(row, shown)
+	}
+
+	a.promptSave(fmt.Sprintf("%d %s with %s as %s", len(rows), plural(int64(len(rows)), "row", "rows"), which, export.Extensions), title+".csv",
+		func(a *App, path string) tea.Cmd {
+			return func() tea.Msg {
+				count, err := writeExport(path, title, func(w export.Writer) (int64, error) {
+					if err := w.Header(header); err != nil {
+						return 0, err
+					}
+					for i, row := range cells {
+						if err := w.Row(row); err != nil {
+							return int64(i), err
+						}
+					}
+					return int64(len(cells)), nil
+				})
+				return exportDoneMsg{path: path, rows: count, err: err}
+			}
+		})
+}
+
+// pick returns a copy of the elements of a row at the given indexes
+func pick[T any](row []T, indexes []int) []T {
+	picked := make([]T, len(indexes))
+	for i, index := range indexes {
+		picked[i] = row[index]
+	}
+	return picked
+}
+
+// exportTable asks for a file to write every row of the selected relation
+// to, streamed from the server in the current sort order. The export runs in
+// a slot of its own so that only the cancel key stops it.
+func (a *App) exportTable() {
+	if a.busy(slotExport) {
+		return
+	}
+	database := a.db
+	table := a.model.SelectedTable
+	sortKeys := a.model.SortKeys
+	keyColumns := a.model.Paging.KeyColumns
+	a.promptSave(fmt.Sprintf("all rows and columns of %s as %s", table.QualifiedName(), export.Extensions), table.QualifiedName()+".csv",
+		func(a *App, path string) tea.Cmd {
+			return a.run(slotExport, "Exporting "+table.QualifiedName(), func(ctx context.Context) tea.Msg {
+				count, err := writeExport(path, table.QualifiedName(), func(w export.Writer) (int64, error) {
+					return database.StreamTable(ctx, table, sortKeys, keyColumns, w)
+				})
+				return exportDoneMsg{path: path, rows: count, err: err}
+			})
+		})
+}
+
+// writeExport writes a file in the format its extension names, filled by
+// write. A failed export leaves any existing file as it was.
+func writeExport(path, title string, write func(w export.Writer) (int64, error)) (int64, error) {
+	format, err := export.FormatFor(path)
+	if err != nil {
+		return 0, err
+	}
+	var count int64
+	err = replaceFile(path, func(f *os.File) error {
+		w := export.NewWriter(f, format, title)
+		var err error
+		if count, err = write(w); err != nil {
+			return err
+		}
+		return w.Close()
+	})
+	return count, err
+}
+
+// showExport reports the outcome of an export
+func (a *App) showExport(msg exportDoneMsg) {
+	switch {
+	case errors.Is(msg.err, context.Canceled):
+		a.model.StatusMessage = fmt.Sprintf("Export cancelled after %d %s", msg.rows, plural(msg.rows, "row", "rows"))
+	case msg.err != nil:
+		a.model.StatusMessage = "Export failed: " + queryError(msg.err)
+	default:
+		a.model.StatusMessage = fmt.Sprintf("Exported %d %s to %s", msg.rows, plural(msg.rows, "row", "rows"), msg.path)
+	}
+}
@@ -0,0 +1,139 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/history"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+)
+
+// connectionKey names the current connection in the query history: the
+// profile name, or the user, hosts and database when no profile is in use
+func (a *App) connectionKey() string {
+	if a.model.ActiveProfile != "" {
+		return a.model.ActiveProfile
+	}
+	return a.target
+}
+
+// recordHistory adds a statement run in the SQL editor to the query history
+func (a *App) recordHistory(msg queryResultMsg) {
+	entry := history.Entry{
+		SQL:        msg.source,
+		Connection: a.connectionKey(),
+		Time:       msg.started,
+		Duration:   msg.duration,
+	}
+	if msg.err != nil {
+		entry.Error = queryError(msg.err)
+	} else {
+		entry.Rows = msg.result.RowsAffected
+	}
+	if err := history.Append(entry); err != nil {
+		a.model.StatusMessage = fmt.Sprintf("Could not save query history: %v", err)
+	}
+}
+
+// openHistory shows the query history of the current connection
+func (a *App) openHistory() tea.Cmd {
+	entries, err := history.Load(a.connectionKey())
+	if err != nil {
+		a.model.StatusMessage = err.Error()
+		return nil
+	}
+
+	items := make([]model.HistoryItem, len(entries))
+	for i, entry := range entries {
+		items[i] = model.HistoryItem{
+			SQL:        entry.SQL,
+			Connection: entry.Connection,
+			Time:       entry.Time,
+			Duration:   entry.Duration,
+			Rows:       entry.Rows,
+			Error:      entry.Error,
+		}
+	}
+	a.model.QueryEditor.Blur()
+	a.model.Focused = model.FocusHistory
+	a.model.HistoryList.ResetFilter()
+	return a.model.HistoryList.SetItems(ui.CreateHistoryItems(items))
+}
+
+// updateHistory handles a key press in the query history browser
+func (a *App) updateHistory(msg tea.KeyMsg) tea.Cmd {
+	// Clearing the history takes a second D; any other key calls it off
+	confirmPrune := a.confirmPrune
+	a.confirmPrune = false
+
+	switch {
+	case key.Matches(msg, a.keys.Select):
+		if i, ok := a.model.HistoryList.SelectedItem().(model.HistoryItem); ok {
+			// Re-run the statement in the editor
+			a.model.QueryEditor.SetValue(i.SQL)
+			a.model.Focused = model.FocusQuery
+			return tea.Batch(a.focusQueryEditor(), a.runQuery())
+		}
+		return nil
+	case key.Matches(msg, a.keys.Delete):
+		if i, ok := a.model.HistoryList.SelectedItem().(model.HistoryItem); ok {
+			if err := history.Delete(history.Entry{Connection: i.Connection, Time: i.Time}); err != nil {
+				a.model.StatusMessage = err.Error()
+				return nil
+			}
+			if index := a.historyIndex(i); index >= 0 {
+				a.model.HistoryList.RemoveItem(index)
+			}
+		}
+		return nil
+	case key.Matches(msg, a.keys.Prune):
+		if !confirmPrune {
+			a.confirmPrune = true
+			a.model.StatusMessage = fmt.Sprintf("Press D again to delete all %d statements run on %s",
+				len(a.model.HistoryList.Items()), a.connectionKey())
+			return nil
+		}
+		if err := history.Prune(a.connectionKey()); err != nil {
+			a.model.StatusMessage = err.Error()
+			return nil
+		}
+		a.model.StatusMessage = "Query history cleared"
+		return a.model.HistoryList.SetItems(nil)
+	}
+
+	var cmd tea.Cmd
+	a.model.HistoryList, cmd = a.model.HistoryList.Update(msg)
+	return cmd
+}
+
+// historyIndex finds an entry among all items of the history list, which
+// differs from the selected index while a filter is applied
+func (a *App) historyIndex(item model.HistoryItem) int {
+	for i, listItem := range a.model.HistoryList.Items() {
+		if h, ok := listItem.(model.HistoryItem); ok && h.Connection == item.Connection && h.Time.Equal(item.Time) {
+			return i
+		}
+	}
+	return -1
+}
+
+// historyFiltering reports whether the history browser's filter input has
+// the keyboard
+func (a *App) historyFiltering() bool {
+	return a.model.Focused == model.FocusHistory && a.model.HistoryList.FilterState() == list.Filtering
+}
+
+// leaveHistory handles Esc in the history browser: it clears an applied
+// filter first, then returns to the SQL editor
+func (a *App) leaveHistory() tea.Cmd {
+	if a.model.HistoryList.FilterState() == list.FilterApplied {
+		a.model.HistoryList.ResetFilter()
+		return nil
+	}
+	a.model.Focused = model.FocusQuery
+	return a.focusQueryEditor()
+}
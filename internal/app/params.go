@@ -0,0 +1,117 @@
+package app
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/config"
+	"github.com/ddoemonn/go-dot-dot/internal/db"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+)
+
+// loadSavedQueries reads the saved queries file for the sidebar
+func (a *App) loadSavedQueries() {
+	queries, err := config.LoadSavedQueries()
+	if err != nil {
+		a.model.StatusMessage = err.Error()
+		return
+	}
+	a.model.SavedQueries = make([]model.SavedQueryItem, len(queries))
+	for i, query := range queries {
+		a.model.SavedQueries[i] = model.SavedQueryItem{Name: query.Name, Summary: query.Description, SQL: query.SQL}
+	}
+}
+
+// openSavedQuery puts a saved query into the SQL editor and runs it, asking
+// for its parameter values first if it has any
+func (a *App) openSavedQuery(query model.SavedQueryItem) tea.Cmd {
+	a.model.QueryEditor.SetValue(query.SQL)
+	return a.prepareStatement(query.SQL, query.Name)
+}
+
+// prepareStatement runs a statement right away when it has no parameters and
+// opens the parameters form otherwise
+func (a *App) prepareStatement(source, title string) tea.Cmd {
+	sql, names := db.ParseParams(source)
+	if len(names) == 0 {
+		a.model.Focused = model.FocusQuery
+		return tea.Batch(a.focusQueryEditor(), a.runStatement(source, sql, nil))
+	}
+
+	a.model.ParamReturn = a.model.Focused
+	a.model.ParamTitle = title
+	a.model.ParamSource = source
+	a.model.ParamSQL = sql
+	a.model.ParamNames = names
+	a.model.ParamInputs = make([]textinput.Model, len(names))
+	for i, name := range names {
+		// Values are remembered by name for the next statement that asks
+		a.model.ParamInputs[i] = ui.CreateParamInput(a.paramValues[name])
+	}
+	a.model.ParamFocus = 0
+	a.model.QueryEditor.Blur()
+	a.model.Focused = model.FocusParams
+	return a.model.ParamInputs[0].Focus()
+}
+
+// updateParams handles a key press in the parameters form
+func (a *App) updateParams(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "ctrl+c":
+		a.cancelQueries()
+		return tea.Quit
+	case "esc":
+		a.model.Focused = a.model.ParamReturn
+		if a.model.Focused == model.FocusQuery {
+			return a.focusQueryEditor()
+		}
+		return nil
+	case "tab", "down":
+		return a.focusParam(a.model.ParamFocus + 1)
+	case "shift+tab", "up":
+		return a.focusParam(a.model.ParamFocus - 1)
+	case "enter":
+		if a.model.ParamFocus < len(a.model.ParamInputs)-1 {
+			return a.focusParam(a.model.ParamFocus + 1)
+		}
+		return a.runWithParams()
+	}
+	if key.Matches(msg, a.keys.RunQuery) {
+		return a.runWithParams()
+	}
+
+	var cmd tea.Cmd
+	a.model.ParamInputs[a.model.ParamFocus], cmd = a.model.ParamInputs[a.model.ParamFocus].Update(msg)
+	return cmd
+}
+
+// focusParam moves the focus to another input of the parameters form,
+// wrapping around at either end
+func (a *App) focusParam(index int) tea.Cmd {
+	count := len(a.model.ParamInputs)
+	a.model.ParamInputs[a.model.ParamFocus].Blur()
+	a.model.ParamFocus = (index + count) % count
+	return a.model.ParamInputs[a.model.ParamFocus].Focus()
+}
+
+// runWithParams binds the values of the parameters form and runs the
+// statement. Values are sent as text for the server to convert; an empty
+// value is NULL.
+func (a *App) runWithParams() tea.Cmd {
+	if a.paramValues == nil {
+		a.paramValues = make(map[string]string)
+	}
+	args := make([]any, len(a.model.ParamInputs))
+	for i, input := range a.model.ParamInputs {
+		value := input.Value()
+		a.paramValues[a.model.ParamNames[i]] = value
+		if value != "" {
+			args[i] = value
+		}
+	}
+
+	a.model.Focused = model.FocusQuery
+	return tea.Batch(a.focusQueryEditor(), a.runStatement(a.model.ParamSource, a.model.ParamSQL, args))
+}
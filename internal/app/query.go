@@ -0,0 +1,200 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+	"github.com/ddoemonn/go-dot-dot/internal/utils"
+)
+
+// queryResultMsg carries the outcome of a statement run in the SQL editor
+type queryResultMsg struct {
+	source   string // Statement as written
+	sql      string // Statement as sent, with $n placeholders
+	started  time.Time
+	duration time.Duration
+	result   *model.QueryResult
+	err      error
+}
+
+// openQueryEditor switches to the SQL editor. An empty editor starts with a
+// query on the selected relation.
+func (a *App) openQueryEditor() tea.Cmd {
+	if a.model.QueryEditor.Value() == "" {
+		table := a.model.SelectedTable
+		if a.model.Focused == model.FocusTableList {
+			if i, ok := a.model.TableList.SelectedItem().(model.TableItem); ok {
+				table = i
+			}
+		}
+		if table.Name != "" {
+			a.model.QueryEditor.SetValue(fmt.Sprintf("SELECT * FROM %s LIMIT 100;", table.Identifier()))
+		}
+	}
+	a.model.Focused = model.FocusQuery
+	return a.focusQueryEditor()
+}
+
+// focusQueryEditor moves the keyboard focus from the result grid to the editor
+func (a *App) focusQueryEditor() tea.Cmd {
+	a.model.QueryResultsFocused = false
+	return a.model.QueryEditor.Focus()
+}
+
+// updateQueryEditor handles a key press while the editor has the focus. The
+// editor takes every key except the few that run, leave or cancel.
+func (a *App) updateQueryEditor(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case msg.String() == "ctrl+c":
+		a.cancelQueries()
+		return tea.Quit
+	case key.Matches(msg, a.keys.Cancel):
+		if a.cancelQueries() {
+			a.model.StatusMessage = "Cancelling query..."
+		}
+		return nil
+	case key.Matches(msg, a.keys.RunQuery):
+		return a.runQuery()
+	case key.Matches(msg, a.keys.SwitchPane):
+		if a.model.QueryResult != nil && len(a.model.QueryResult.Columns) > 0 {
+			a.model.QueryEditor.Blur()
+			a.model.QueryResultsFocused = true
+		}
+		return nil
+	case key.Matches(msg, a.keys.History):
+		return a.openHistory()
+	case key.Matches(msg, a.keys.Back):
+		a.model.QueryEditor.Blur()
+		a.model.Focused = model.FocusTableList
+		return nil
+	}
+
+	var cmd tea.Cmd
+	a.model.QueryEditor, cmd = a.model.QueryEditor.Update(msg)
+	return cmd
+}
+
+// runQuery executes the statement in the editor, asking for its parameter
+// values first if it has any
+func (a *App) runQuery() tea.Cmd {
+	source := strings.TrimSpace(a.model.QueryEditor.Value())
+	if source == "" {
+		return nil
+	}
+	return a.prepareStatement(source, "Statement parameters")
+}
+
+// runStatement executes a statement in the background and shows the outcome
+// in the SQL editor
+func (a *App) runStatement(source, sql string, args []any) tea.Cmd {
+	database := a.db
+	return a.startQuery("Running query", func(ctx context.Context) tea.Msg {
+		msg := queryResultMsg{source: source, sql: sql, started: time.Now()}
+		msg.result, msg.err = database.ExecuteQuery(ctx, sql, args...)
+		msg.duration = time.Since(msg.started)
+		return msg
+	})
+}
+
+// showQueryResult puts the outcome of a statement below the editor
+func (a *App) showQueryResult(msg queryResultMsg) {
+	if msg.err != nil {
+		a.model.QueryResult = nil
+		a.model.QueryError = formatQueryError(msg.sql, msg.err)
+		a.model.QueryResultsFocused = false
+		return
+	}
+	a.model.QueryError = ""
+	a.model.QueryResult = msg.result
+	a.model.QueryScrollOffset = 0
+	a.rebuildQueryTable()
+}
+
+// rebuildQueryTable recreates the result grid, e.g. after scrolling sideways
+func (a *App) rebuildQueryTable() {
+	result := a.model.QueryResult
+	if result == nil {
+		return
+	}
+	a.model.QueryTable = ui.CreateTableData(result.Columns, result.Rows, a.model.QueryScrollOffset, nil)
+	a.resizeQueryTable()
+}
+
+// resizeQueryTable fits the result grid into the space below the editor
+func (a *App) resizeQueryTable() {
+	a.model.QueryTable.SetHeight(utils.Max(5, a.model.Height-ui.QueryEditorHeight-24))
+	a.model.QueryTable.SetWidth(a.model.Width - 8)
+}
+
+// showQueryRowDetails opens the detail view for the result row under the cursor
+func (a *App) showQueryRowDetails() {
+	result := a.model.QueryResult
+	rowIndex := a.model.QueryTable.Cursor()
+	if result == nil || rowIndex < 0 || rowIndex >= len(result.Rows) {
+		return
+	}
+	a.model.SelectedRow = rowIndex
+	a.model.SelectedRowData = rowData(result.Columns, result.Rows[rowIndex])
+	a.model.DetailReturn = model.FocusQuery
+	a.model.Focused = model.FocusDetail
+}
+
+// rowData maps column names to the values of a row for the detail view.
+// Repeated column names, common in joins, are numbered so none is lost.
+func rowData(columns []string, row []model.Cell) map[string]model.Cell {
+	data := make(map[string]model.Cell, len(columns))
+	for i, name := range utils.UniqueNames(columns) {
+		if i >= len(row) {
+			break
+		}
+		data[name] = row[i]
+	}
+	return data
+}
+
+// formatQueryError describes a failed statement the way psql does, pointing
+// at the error position and adding the server's detail and hint
+func formatQueryError(sql string, err error) string {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return queryError(err)
+	}
+
+	lines := []string{fmt.Sprintf("%s: %s (SQLSTATE %s)", pgErr.Severity, pgErr.Message, pgErr.Code)}
+	if pgErr.Position > 0 {
+		lineNumber, column, text := errorLine(sql, int(pgErr.Position))
+		prefix := fmt.Sprintf("LINE %d: ", lineNumber)
+		lines = append(lines, prefix+text, strings.Repeat(" ", len(prefix)+column-1)+"^")
+	}
+	if pgErr.Detail != "" {
+		lines = append(lines, "DETAIL: "+pgErr.Detail)
+	}
+	if pgErr.Hint != "" {
+		lines = append(lines, "HINT: "+pgErr.Hint)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// errorLine finds the line holding a 1-based character position of a
+// statement, returning the line number, the column within it and its text
+func errorLine(sql string, position int) (int, int, string) {
+	lines := strings.Split(sql, "\n")
+	for i, line := range lines {
+		runes := []rune(line)
+		if position <= len(runes)+1 || i == len(lines)-1 {
+			text := strings.ReplaceAll(line, "\t", " ")
+			return i + 1, utils.Min(position, len(runes)+1), text
+		}
+		position -= len(runes) + 1 // The line and its newline
+	}
+	return 1, 1, ""
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+)
+
+// navEntry is a relation left by following a foreign key, for Esc to return
+// to
+type navEntry struct {
+	table model.TableItem
+	match *model.RowMatch
+}
+
+// label describes the entry in the breadcrumbs
+func (e navEntry) label() string {
+	if e.match == nil {
+		return e.table.QualifiedName()
+	}
+	return fmt.Sprintf("%s (%s)", e.table.QualifiedName(), e.match)
+}
+
+// setTrail adopts the chain of relations that led to the one now shown
+func (a *App) setTrail(trail []navEntry) {
+	a.navStack = trail
+	a.model.Breadcrumbs = make([]string, len(trail))
+	for i, entry := range trail {
+		a.model.Breadcrumbs[i] = entry.label()
+	}
+}
+
+// navigate opens a relation reached from the one shown, which Esc returns to
+func (a *App) navigate(table model.TableItem, match *model.RowMatch) tea.Cmd {
+	trail := append(append([]navEntry(nil), a.navStack...), navEntry{table: a.model.SelectedTable, match: a.model.Paging.Match})
+	return a.openTableAt(table, match, trail)
+}
+
+// navigateBack returns to the relation the shown one was reached from
+func (a *App) navigateBack() tea.Cmd {
+	last := a.navStack[len(a.navStack)-1]
+	return a.openTableAt(last.table, last.match, a.navStack[:len(a.navStack)-1])
+}
+
+// detailField returns the column under the cursor of the detail view of a
+// table row
+func (a *App) detailField() (string, bool) {
+	if a.model.DetailReturn != model.FocusTableData {
+		a.model.StatusMessage = "Foreign keys can only be followed from table rows"
+		return "", false
+	}
+	fields := ui.DetailFields(a.model.SelectedRowData)
+	if a.model.DetailCursor >= len(fields) {
+		return "", false
+	}
+	return fields[a.model.DetailCursor], true
+}
+
+// rowValues returns the values of some columns of the row in the detail
+// view, failing when one is NULL since it then references no row
+func (a *App) rowValues(columns []string) ([]string, bool) {
+	values := make([]string, len(columns))
+	for i, column := range columns {
+		cell := a.model.SelectedRowData[column]
+		if cell.Null {
+			a.model.StatusMessage = fmt.Sprintf("%s is NULL, so there is no row to follow", column)
+			return nil, false
+		}
+		values[i] = cell.Text()
+	}
+	return values, true
+}
+
+// followReference opens the row that the foreign key column under the
+// detail cursor references
+func (a *App) followReference() tea.Cmd {
+	column, ok := a.detailField()
+	if !ok {
+		return nil
+	}
+	for _, fk := range a.model.ForeignKeys {
+		for _, c := range fk.Columns {
+			if c != column {
+				continue
+			}
+			values, ok := a.rowValues(fk.Columns)
+			if !ok {
+				return nil
+			}
+			return a.navigate(fk.RefTable, &model.RowMatch{Columns: fk.RefColumns, Values: values})
+		}
+	}
+	a.model.StatusMessage = fmt.Sprintf("%s is not part of a foreign key", column)
+	return nil
+}
+
+// showReferences lists the foreign keys of other relations that reference
+// the selected one, to open the rows pointing at the row in the detail view
+func (a *App) showReferences() {
+	if _, ok := a.detailField(); !ok {
+		return
+	}
+	if len(a.model.ReferencingKeys) == 0 {
+		a.model.StatusMessage = fmt.Sprintf("No foreign keys reference %s", a.model.SelectedTable.QualifiedName())
+		return
+	}
+	a.model.ReferenceList.SetItems(ui.CreateReferenceItems(a.model.ReferencingKeys))
+	a.model.ReferenceList.ResetSelected()
+	a.model.Focused = model.FocusReferences
+}
+
+// updateReferences handles a key press in the referencing rows picker
+func (a *App) updateReferences(msg tea.KeyMsg) tea.Cmd {
+	if key.Matches(msg, a.keys.Select) {
+		if i, ok := a.model.ReferenceList.SelectedItem().(model.ReferenceItem); ok {
+			values, ok := a.rowValues(i.Key.RefColumns)
+			if !ok {
+				return nil
+			}
+			return a.navigate(i.Key.Table, &model.RowMatch{Columns: i.Key.Columns, Values: values})
+		}
+		return nil
+	}
+	var cmd tea.Cmd
+	a.model.ReferenceList, cmd = a.model.ReferenceList.Update(msg)
+	return cmd
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/ui"
+	"github.com/ddoemonn/go-dot-dot/internal/utils"
+)
+
+// tabLoadedMsg delivers the catalog details shown on a table view tab; only
+// the field for the tab is set
+type tabLoadedMsg struct {
+	table       model.TableItem
+	tab         int
+	columns     []model.ColumnDetail
+	indexes     []model.IndexInfo
+	constraints []model.ConstraintInfo
+	triggers    []model.TriggerInfo
+	err         error
+}
+
+// showingData reports whether the data grid of the table view is on screen
+func (a *App) showingData() bool {
+	return a.model.Focused == model.FocusTableData && a.model.TableTab == model.TabData
+}
+
+// switchTab moves to the next tab of the table view, loading what it shows
+func (a *App) switchTab() tea.Cmd {
+	a.model.TableTab = (a.model.TableTab + 1) % len(model.TableTabs)
+	if a.model.TableTab == model.TabData {
+		return nil
+	}
+	return a.loadTab(a.model.TableTab)
+}
+
+// loadTab fetches the catalog details for a tab of the selected relation.
+// They are loaded afresh every time the tab is shown.
+func (a *App) loadTab(tab int) tea.Cmd {
+	database := a.db
+	table := a.model.SelectedTable
+	a.model.TabTable = ui.CreateTableData(nil, nil, 0, nil)
+	a.model.TabEmpty = ""
+	a.tabDetails = tabLoadedMsg{}
+	return a.startQuery("Loading "+model.TableTabs[tab], func(ctx context.Context) tea.Msg {
+		msg := tabLoadedMsg{table: table, tab: tab}
+		switch tab {
+		case model.TabStructure:
+			msg.columns, msg.err = database.FetchColumnDetails(ctx, table)
+		case model.TabIndexes:
+			msg.indexes, msg.err = database.FetchIndexes(ctx, table)
+		case model.TabConstraints:
+			msg.constraints, msg.err = database.FetchConstraints(ctx, table)
+		case model.TabTriggers:
+			msg.triggers, msg.err = database.FetchTriggers(ctx, table)
+		}
+		return msg
+	})
+}
+
+// showTab puts freshly loaded catalog details into their tab
+func (a *App) showTab(msg tabLoadedMsg) {
+	if msg.err != nil {
+		a.model.StatusMessage = "Failed to load " + model.TableTabs[msg.tab] + ": " + queryError(msg.err)
+		return
+	}
+	if msg.table != a.model.SelectedTable || msg.tab != a.model.TableTab {
+		return
+	}
+
+	a.tabDetails = msg
+	var count int
+	switch msg.tab {
+	case model.TabStructure:
+		a.model.TabTable, count = ui.CreateStructureTable(msg.columns), len(msg.columns)
+	case model.TabIndexes:
+		a.model.TabTable, count = ui.CreateIndexTable(msg.indexes), len(msg.indexes)
+	case model.TabConstraints:
+		a.model.TabTable, count = ui.CreateConstraintTable(msg.constraints), len(msg.constraints)
+	case model.TabTriggers:
+		a.model.TabTable, count = ui.CreateTriggerTable(msg.triggers), len(msg.triggers)
+	}
+	a.model.TabEmpty = ""
+	if count == 0 {
+		a.model.TabEmpty = "No " + strings.ToLower(model.TableTabs[msg.tab])
+	}
+	a.resizeTabs()
+}
+
+// resizeTabs fits the grid of the table view tabs to the window
+func (a *App) resizeTabs() {
+	listWidth := utils.Min(30, a.model.Width/4)
+	a.model.TabTable.SetHeight(utils.Max(5, a.model.Height-12))
+	a.model.TabTable.SetWidth(a.model.Width - listWidth - 8)
+}
+
+// updateTab handles a key press on a table view tab other than the data
+func (a *App) updateTab(msg tea.KeyMsg) tea.Cmd {
+	switch {
+	case key.Matches(msg, a.keys.SwitchPane):
+		return a.switchTab()
+	case key.Matches(msg, a.keys.Left):
+		a.model.Focused = model.FocusTableList
+		return nil
+	case key.Matches(msg, a.keys.ShowDDL):
+		return a.showDDL()
+	}
+
+	var cmd tea.Cmd
+	a.model.TabTable, cmd = a.model.TabTable.Update(msg)
+	return cmd
+}
@@ -1,55 +1,140 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/joho/godotenv"
 )
 
+// SSLModes lists the sslmode values understood by libpq and pgx
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Errors returned while loading the configuration
+var (
+	// ErrSetupCancelled is returned when the user quits the setup wizard
+	ErrSetupCancelled = errors.New("setup cancelled")
+	// ErrNotConfigured is returned when nothing is configured and the setup
+	// wizard may not run
+	ErrNotConfigured = errors.New("no connection settings found")
+	// ErrProfileNotFound is returned for an unknown profile name
+	ErrProfileNotFound = errors.New("profile not found")
+)
+
 // Config holds application configuration
 type Config struct {
-	DB DBConfig
+	DB             DBConfig
+	Profile        string // Name of the profile the settings came from, if any
+	PromptPassword bool   // The password must be asked for before connecting
 }
 
-// DBConfig holds database connection parameters
+// DBConfig holds database connection parameters. When DSN is set it is used
+// as-is and the discrete fields are ignored. Empty fields are resolved the
+// way libpq does it: from the pg_service.conf service, then the PG*
+// environment variables, then built-in defaults, with the password looked up
+// in ~/.pgpass when none is given.
 type DBConfig struct {
-	User     string
-	Password string
-	Name     string
-	Host     string
-	Port     string
+	DSN      string `json:"dsn,omitempty"` // Full postgres:// URL or libpq key=value string
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Host     string `json:"host,omitempty"` // Host name, IP address or Unix socket directory
+	Port     string `json:"port,omitempty"`
+	Service  string `json:"service,omitempty"` // Service name from pg_service.conf
+
+	SSLMode     string `json:"sslmode,omitempty"`
+	SSLRootCert string `json:"sslrootcert,omitempty"` // Path to the root CA certificate
+	SSLCert     string `json:"sslcert,omitempty"`     // Path to the client certificate
+	SSLKey      string `json:"sslkey,omitempty"`      // Path to the client private key
+
+	Params map[string]string `json:"params,omitempty"` // Additional libpq parameters, e.g. application_name
+
+	ReadOnly bool `json:"read_only,omitempty"` // Open every transaction read-only
 }
 
-// Load loads configuration from environment variables
-func Load() (*Config, error) {
-	// Check if .env file exists
-	if _, err := os.Stat(".env"); os.IsNotExist(err) {
-		return RunSetup()
+// Default returns the configuration used when nothing else is configured,
+// which leaves every setting to the libpq defaults
+func Default() *Config {
+	return &Config{}
+}
+
+// Load loads configuration from the .env file in the working directory,
+// falling back to the default profile and finally the setup wizard. When
+// setup is false ErrNotConfigured is returned instead of running the wizard.
+func Load(setup bool) (*Config, error) {
+	if hasDotEnv() {
+		return fromEnv()
 	}
 
-	err := godotenv.Load()
+	profiles, err := LoadProfiles()
 	if err != nil {
-		return RunSetup()
+		return nil, err
+	}
+	if profiles.Default != "" {
+		return LoadProfile(profiles.Default)
 	}
 
-	// Check if required environment variables are set
-	if os.Getenv("DB_USER") == "" && os.Getenv("DB_HOST") == "" {
-		return RunSetup()
+	// Standard PG* variables or a password file are enough to connect
+	if libpqConfigured() {
+		return Default(), nil
 	}
 
-	// Read DB credentials from .env or environment
-	cfg := &Config{
-		DB: DBConfig{
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", ""),
-			Name:     getEnv("DB_NAME", "postgres"),
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-		},
+	if !setup {
+		return nil, ErrNotConfigured
 	}
+	return RunSetup()
+}
 
-	return cfg, nil
+// libpqEnvVars are the environment variables libpq reads connection settings from
+var libpqEnvVars = []string{
+	"PGHOST", "PGHOSTADDR", "PGPORT", "PGDATABASE", "PGUSER", "PGPASSWORD",
+	"PGPASSFILE", "PGSERVICE", "PGSERVICEFILE", "PGSSLMODE",
+}
+
+// libpqConfigured reports whether connection settings are available through
+// the standard PG* environment variables or a password file
+func libpqConfigured() bool {
+	for _, name := range libpqEnvVars {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return false
+	}
+	passfile := filepath.Join(home, ".pgpass")
+	if runtime.GOOS == "windows" {
+		passfile = filepath.Join(os.Getenv("APPDATA"), "postgresql", "pgpass.conf")
+	}
+	_, err = os.Stat(passfile)
+	return err == nil
+}
+
+// hasDotEnv reports whether a .env file with database settings was loaded
+func hasDotEnv() bool {
+	// Check if .env file exists
+	if _, err := os.Stat(".env"); os.IsNotExist(err) {
+		return false
+	}
+
+	if err := godotenv.Load(); err != nil {
+		return false
+	}
+
+	// Check if required environment variables are set
+	return os.Getenv("DB_USER") != "" || os.Getenv("DB_HOST") != "" ||
+		os.Getenv("DB_DSN") != "" || os.Getenv("DB_SERVICE") != ""
 }
 
 // LoadFromEnv loads configuration directly from the .env file
@@ -60,28 +145,237 @@ func LoadFromEnv() (*Config, error) {
 		return nil, fmt.Errorf("error loading .env file: %w", err)
 	}
 
-	// Read DB credentials from .env or environment
+	return fromEnv()
+}
+
+// fromEnv reads DB credentials from .env or environment. Settings left unset
+// fall back to the libpq defaults and PG* variables.
+func fromEnv() (*Config, error) {
+	params, err := url.ParseQuery(getEnv("DB_PARAMS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_PARAMS: %w", err)
+	}
+
 	cfg := &Config{
 		DB: DBConfig{
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", ""),
-			Name:     getEnv("DB_NAME", "postgres"),
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
+			DSN:         getEnv("DB_DSN", ""),
+			User:        getEnv("DB_USER", ""),
+			Password:    getEnv("DB_PASSWORD", ""),
+			Name:        getEnv("DB_NAME", ""),
+			Host:        getEnv("DB_HOST", ""),
+			Port:        getEnv("DB_PORT", ""),
+			Service:     getEnv("DB_SERVICE", ""),
+			SSLMode:     getEnv("DB_SSLMODE", ""),
+			SSLRootCert: getEnv("DB_SSLROOTCERT", ""),
+			SSLCert:     getEnv("DB_SSLCERT", ""),
+			SSLKey:      getEnv("DB_SSLKEY", ""),
 		},
 	}
+	for key := range params {
+		if cfg.DB.Params == nil {
+			cfg.DB.Params = make(map[string]string)
+		}
+		cfg.DB.Params[key] = params.Get(key)
+	}
 
 	return cfg, nil
 }
 
-// ConnectionString returns a formatted connection string
+// ConnectionString returns a connection URL with every component escaped, so
+// passwords and names may contain characters such as @, : or /
 func (c *DBConfig) ConnectionString() string {
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s",
-		c.User, c.Password, c.Host, c.Port, c.Name)
+	if c.DSN != "" {
+		return dsnWithPassword(c.DSN, c.User, c.Password)
+	}
+
+	query := url.Values{}
+	for key, value := range c.Params {
+		query.Set(key, value)
+	}
+	setIfNotEmpty(query, "service", c.Service)
+	setIfNotEmpty(query, "sslmode", c.SSLMode)
+	setIfNotEmpty(query, "sslrootcert", c.SSLRootCert)
+	setIfNotEmpty(query, "sslcert", c.SSLCert)
+	setIfNotEmpty(query, "sslkey", c.SSLKey)
+
+	u := &url.URL{
+		Scheme: "postgres",
+		Path:   "/" + c.Name, // An empty name leaves the database to libpq
+	}
+	switch {
+	case c.Password != "" && c.User == "":
+		// A URL cannot hold a password without a user, and an empty user
+		// would stop libpq from picking the default one
+		query.Set("password", c.Password)
+	case c.Password != "":
+		u.User = url.UserPassword(c.User, c.Password)
+	case c.User != "":
+		u.User = url.User(c.User)
+	}
+	switch {
+	case strings.Contains(c.Host, "/") || strings.Contains(c.Host, ","):
+		// Unix socket directories cannot go in the authority part, and there
+		// a port applies to the host before it rather than to every host
+		query.Set("host", c.Host)
+		setIfNotEmpty(query, "port", c.Port)
+	case c.Port != "":
+		u.Host = net.JoinHostPort(strings.Trim(c.Host, "[]"), c.Port)
+	case strings.Contains(c.Host, ":") && !strings.HasPrefix(c.Host, "["):
+		u.Host = "[" + c.Host + "]" // IPv6 address
+	default:
+		u.Host = c.Host
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
 }
 
-// ConnectionDetails returns a user-friendly connection string for display
+// ConnectionDetails returns a user-friendly connection string for display,
+// showing the settings as resolved through the service file, PG* variables
+// and defaults
 func (c *DBConfig) ConnectionDetails() string {
-	return fmt.Sprintf("Connected to: %s@%s:%s/%s",
-		c.User, c.Host, c.Port, c.Name)
+	target := c.Target()
+	if target == "" {
+		return "Connected to: (invalid connection settings)"
+	}
+	return "Connected to: " + target
+}
+
+// Target returns the user, hosts and database the settings resolve to, as
+// user@host:port/database, or an empty string for invalid settings
+func (c *DBConfig) Target() string {
+	parsed, err := pgconn.ParseConfig(c.ConnectionString())
+	if err != nil {
+		return ""
+	}
+	hosts := []string{hostPort(parsed.Host, parsed.Port)}
+	for _, fallback := range parsed.Fallbacks {
+		// sslmode=prefer and allow try each host twice
+		if host := hostPort(fallback.Host, fallback.Port); host != hosts[len(hosts)-1] {
+			hosts = append(hosts, host)
+		}
+	}
+	return fmt.Sprintf("%s@%s/%s", parsed.User, strings.Join(hosts, ","), parsed.Database)
+}
+
+// hostPort renders a host and port for display
+func hostPort(host string, port uint16) string {
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// Override replaces the settings that are set in override, as given on the
+// command line. A DSN cannot be combined with individual settings.
+func (c *DBConfig) Override(override DBConfig) error {
+	if override.DSN != "" {
+		c.DSN = override.DSN
+		c.Password = ""
+	}
+	discrete := override.Host != "" || override.Port != "" || override.Name != "" || override.User != ""
+	if discrete && c.DSN != "" {
+		return fmt.Errorf("host, port, database and user cannot be combined with a DSN; pass the whole DSN instead")
+	}
+	setString(&c.Host, override.Host)
+	setString(&c.Port, override.Port)
+	setString(&c.Name, override.Name)
+	setString(&c.User, override.User)
+	c.ReadOnly = c.ReadOnly || override.ReadOnly
+	return nil
+}
+
+// setString sets *dst to value unless value is empty
+func setString(dst *string, value string) {
+	if value != "" {
+		*dst = value
+	}
+}
+
+// ValidateSSLMode reports whether mode is empty or a known sslmode
+func ValidateSSLMode(mode string) error {
+	if mode == "" {
+		return nil
+	}
+	for _, known := range SSLModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("SSL mode must be one of: %s", strings.Join(SSLModes, ", "))
+}
+
+// dsnPasswordPattern matches the password setting of a key=value DSN
+var dsnPasswordPattern = regexp.MustCompile(`(^|\s)password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// SplitDSNPassword removes the password from a DSN so it can be stored
+// separately, returning the stripped DSN and the password
+func SplitDSNPassword(dsn string) (string, string) {
+	if prefix, userinfo, rest, ok := splitURL(dsn); ok {
+		user, password, ok := strings.Cut(userinfo, ":")
+		if !ok {
+			return dsn, ""
+		}
+		if unescaped, err := url.PathUnescape(password); err == nil {
+			password = unescaped
+		}
+		return prefix + user + "@" + rest, password
+	}
+
+	match := dsnPasswordPattern.FindStringSubmatch(dsn)
+	if match == nil {
+		return dsn, ""
+	}
+	password := match[2]
+	if strings.HasPrefix(password, "'") {
+		password = strings.NewReplacer(`\'`, `'`, `\\`, `\`).Replace(password[1 : len(password)-1])
+	}
+	stripped := strings.TrimSpace(dsnPasswordPattern.ReplaceAllString(dsn, "$1"))
+	return stripped, password
+}
+
+// dsnWithPassword adds a separately stored password to a DSN. A URL without
+// a user takes the profile's; with neither the password is left out, as an
+// empty user in the URL would replace the default one.
+func dsnWithPassword(dsn, user, password string) string {
+	if password == "" {
+		return dsn
+	}
+	if prefix, userinfo, rest, ok := splitURL(dsn); ok {
+		if name, _, _ := strings.Cut(userinfo, ":"); name != "" {
+			user = name
+			if unescaped, err := url.PathUnescape(name); err == nil {
+				user = unescaped
+			}
+		}
+		if user == "" {
+			return dsn
+		}
+		return prefix + url.UserPassword(user, password).String() + "@" + rest
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(password)
+	return dsn + " password='" + escaped + "'"
+}
+
+// splitURL splits a postgres:// URL around its user info. url.Parse is no
+// help here since it rejects the lists of hosts libpq accepts.
+func splitURL(dsn string) (prefix, userinfo, rest string, ok bool) {
+	scheme, after, found := strings.Cut(dsn, "://")
+	if !found || (scheme != "postgres" && scheme != "postgresql") {
+		return "", "", "", false
+	}
+	prefix = scheme + "://"
+	authority := after
+	if end := strings.IndexAny(after, "/?"); end >= 0 {
+		authority = after[:end]
+	}
+	at := strings.LastIndex(authority, "@")
+	if at < 0 {
+		return prefix, "", after, true
+	}
+	return prefix, after[:at], after[at+1:], true
+}
+
+// setIfNotEmpty adds a query parameter only when it has a value
+func setIfNotEmpty(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
 }
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// isolateEnv keeps the PG* variables, service file and ~/.pgpass of the
+// machine running the tests out of the resolved settings
+func isolateEnv(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"PGHOST", "PGPORT", "PGDATABASE", "PGPASSWORD", "PGSERVICE", "PGSERVICEFILE",
+		"PGSSLMODE", "PGSSLROOTCERT", "PGSSLCERT", "PGSSLKEY", "PGAPPNAME", "PGCONNECT_TIMEOUT"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("PGUSER", "tester")
+	t.Setenv("PGPASSFILE", t.TempDir()+"/pgpass")
+	t.Setenv("HOME", t.TempDir())
+}
+
+func TestConnectionString(t *testing.T) {
+	isolateEnv(t)
+	tests := []struct {
+		name     string
+		cfg      DBConfig
+		user     string
+		password string
+		database string
+		details  string
+	}{
+		{
+			name:    "host and port",
+			cfg:     DBConfig{Host: "db.example.com", Port: "5433", User: "alice", Name: "app"},
+			user:    "alice",
+			details: "alice@db.example.com:5433/app",
+		},
+		{
+			name:    "defaults",
+			cfg:     DBConfig{Host: "localhost"},
+			user:    "tester",
+			details: "tester@localhost:5432/",
+		},
+		{
+			name:    "unix socket",
+			cfg:     DBConfig{Host: "/var/run/postgresql", Port: "5433", Name: "app"},
+			user:    "tester",
+			details: "tester@/var/run/postgresql:5433/app",
+		},
+		{
+			name:    "ipv6",
+			cfg:     DBConfig{Host: "::1", Port: "6543", Name: "app"},
+			user:    "tester",
+			details: "tester@[::1]:6543/app",
+		},
+		{
+			name:    "ipv6 without port",
+			cfg:     DBConfig{Host: "fe80::1", Name: "app"},
+			user:    "tester",
+			details: "tester@[fe80::1]:5432/app",
+		},
+		{
+			name:    "ipv6 in brackets",
+			cfg:     DBConfig{Host: "[::1]", Port: "6543", Name: "app"},
+			user:    "tester",
+			details: "tester@[::1]:6543/app",
+		},
+		{
+			name:    "multiple hosts",
+			cfg:     DBConfig{Host: "h1,h2", Name: "app", SSLMode: "disable"},
+			user:    "tester",
+			details: "tester@h1:5432,h2:5432/app",
+		},
+		{
+			name:    "multiple hosts sharing a port",
+			cfg:     DBConfig{Host: "h1,h2", Port: "5433", Name: "app", SSLMode: "disable"},
+			user:    "tester",
+			details: "tester@h1:5433,h2:5433/app",
+		},
+		{
+			name:    "multiple hosts and ports",
+			cfg:     DBConfig{Host: "h1,h2", Port: "5432,5433", Name: "app", SSLMode: "prefer"},
+			user:    "tester",
+			details: "tester@h1:5432,h2:5433/app",
+		},
+		{
+			name:     "password with URL delimiters",
+			cfg:      DBConfig{Host: "h", User: "a@b", Password: "p@ss:w/rd?#%", Name: "my db"},
+			user:     "a@b",
+			password: "p@ss:w/rd?#%",
+			database: "my db",
+			details:  "a@b@h:5432/my db",
+		},
+		{
+			name:     "password with quotes and backslashes",
+			cfg:      DBConfig{Host: "h", Password: `it's a \ "pass"`, Name: "app"},
+			user:     "tester",
+			password: `it's a \ "pass"`,
+			details:  "tester@h:5432/app",
+		},
+		{
+			name:     "keyword DSN with a stored password",
+			cfg:      DBConfig{DSN: "host=h1,h2 port=1,2 dbname=app", Password: `a b'c\`},
+			user:     "tester",
+			password: `a b'c\`,
+			details:  "tester@h1:1,h2:2/app",
+		},
+		{
+			name:     "URL DSN with a stored password",
+			cfg:      DBConfig{DSN: "postgres://alice@h/app", Password: "s@cret"},
+			user:     "alice",
+			password: "s@cret",
+			details:  "alice@h:5432/app",
+		},
+		{
+			name:     "URL DSN without a user takes the profile's",
+			cfg:      DBConfig{DSN: "postgres://h/app", User: "bob", Password: "pw"},
+			user:     "bob",
+			password: "pw",
+			details:  "bob@h:5432/app",
+		},
+		{
+			name:    "URL DSN without any user keeps the default",
+			cfg:     DBConfig{DSN: "postgres://h/app", Password: "pw"},
+			user:    "tester",
+			details: "tester@h:5432/app",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parsed, err := pgconn.ParseConfig(tt.cfg.ConnectionString())
+			if err != nil {
+				t.Fatalf("ParseConfig(%q): %v", tt.cfg.ConnectionString(), err)
+			}
+			if parsed.User != tt.user || parsed.Password != tt.password {
+				t.Errorf("user, password = %q, %q, want %q, %q", parsed.User, parsed.Password, tt.user, tt.password)
+			}
+			if tt.database != "" && parsed.Database != tt.database {
+				t.Errorf("database = %q, want %q", parsed.Database, tt.database)
+			}
+			if got, want := tt.cfg.ConnectionDetails(), "Connected to: "+tt.details; got != want {
+				t.Errorf("ConnectionDetails() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSplitDSNPassword(t *testing.T) {
+	isolateEnv(t)
+	tests := []struct {
+		name     string
+		dsn      string
+		stripped string
+		password string
+	}{
+		{"URL", "postgres://alice:s%40cret@h/app", "postgres://alice@h/app", "s@cret"},
+		{"URL without password", "postgres://alice@h/app", "postgres://alice@h/app", ""},
+		{"URL with several hosts", "postgresql://alice:p%3Aw@h1:5432,h2:5433/app?sslmode=disable", "postgresql://alice@h1:5432,h2:5433/app?sslmode=disable", "p:w"},
+		{"keywords", "host=h password=secret dbname=app", "host=h  dbname=app", "secret"},
+		{"password first", "password=secret host=h", "host=h", "secret"},
+		{"quoted password", `host=h password='it\'s a \\ pass' dbname=app`, "host=h  dbname=app", `it's a \ pass`},
+		{"spaces around =", "host=h password = 'x y'", "host=h", "x y"},
+		{"no password", "host=h dbname=app", "host=h dbname=app", ""},
+		{"socket directory", "host=/tmp password=pw", "host=/tmp", "pw"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stripped, password := SplitDSNPassword(tt.dsn)
+			if stripped != tt.stripped || password != tt.password {
+				t.Fatalf("SplitDSNPassword(%q) = %q, %q, want %q, %q", tt.dsn, stripped, password, tt.stripped, tt.password)
+			}
+
+			// Putting the password back must give the original settings
+			want, err := pgconn.ParseConfig(tt.dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := pgconn.ParseConfig(dsnWithPassword(stripped, "", password))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Password != want.Password || got.Host != want.Host || got.Database != want.Database {
+				t.Errorf("round trip = %q@%s/%s, want %q@%s/%s",
+					got.Password, got.Host, got.Database, want.Password, want.Host, want.Database)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appDirName is the directory used under the user config directory
+const appDirName = "go-dot-dot"
+
+// Profile is a named set of connection settings. The password is normally
+// kept in a secret store under the profile name rather than in the file.
+type Profile struct {
+	Name           string   `json:"name"`
+	DB             DBConfig `json:"db"`
+	SecretStore    string   `json:"secret_store,omitempty"`    // Secret store backend holding the password
+	PromptPassword bool     `json:"prompt_password,omitempty"` // Ask for the password on every connect
+}
+
+// Profiles is the contents of the profiles file
+type Profiles struct {
+	Default  string    `json:"default,omitempty"` // Profile used when none is selected
+	Profiles []Profile `json:"profiles"`
+}
+
+// Dir returns the application's directory under the user config directory
+// (e.g. ~/.config/go-dot-dot on Linux)
+func Dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not locate user config directory: %w", err)
+	}
+	return filepath.Join(base, appDirName), nil
+}
+
+// DataDir returns the directory for data the application accumulates, like
+// the query history: $XDG_DATA_HOME/go-dot-dot (~/.local/share/go-dot-dot by
+// default) on Unix, and the config directory on macOS and Windows
+func DataDir() (string, error) {
+	if base := os.Getenv("XDG_DATA_HOME"); base != "" {
+		return filepath.Join(base, appDirName), nil
+	}
+	switch runtime.GOOS {
+	case "darwin", "ios", "windows", "plan9":
+		return Dir()
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("could not locate user data directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "share", appDirName), nil
+}
+
+// ProfilesPath returns the location of the profiles file
+func ProfilesPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "profiles.json"), nil
+}
+
+// LoadProfiles reads the profiles file. A missing file yields no profiles.
+func LoadProfiles() (*Profiles, error) {
+	path, err := ProfilesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Profiles{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading profiles: %w", err)
+	}
+
+	var profiles Profiles
+	if err := json.Unmarshal(content, &profiles); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return &profiles, nil
+}
+
+// Save writes the profiles file, creating the config directory if needed
+func (p *Profiles) Save() error {
+	path, err := ProfilesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating config directory: %w", err)
+	}
+
+	content, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0600)
+}
+
+// Find returns the profile with the given name
+func (p *Profiles) Find(name string) (*Profile, error) {
+	for i := range p.Profiles {
+		if p.Profiles[i].Name == name {
+			return &p.Profiles[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q", ErrProfileNotFound, name)
+}
+
+// Put adds a profile, replacing any existing profile with the same name
+func (p *Profiles) Put(profile Profile) {
+	for i := range p.Profiles {
+		if p.Profiles[i].Name == profile.Name {
+			p.Profiles[i] = profile
+			return
+		}
+	}
+	p.Profiles = append(p.Profiles, profile)
+}
+
+// LoadProfile loads the configuration for a named profile, fetching its
+// password from the secret store. Profiles that prompt for the password come
+// back with PromptPassword set and no password.
+func LoadProfile(name string) (*Config, error) {
+	profiles, err := LoadProfiles()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := profiles.Find(name)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{DB: profile.DB, Profile: profile.Name, PromptPassword: profile.PromptPassword}
+	if profile.SecretStore != "" && !profile.PromptPassword {
+		store, err := OpenSecretStore(profile.SecretStore)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DB.Password, err = store.Get(profile.Name)
+		if err != nil {
+			return nil, fmt.Errorf("could not read password for profile %q from %s: %w", profile.Name, store.Name(), err)
+		}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// passwordPromptModel asks for a single masked password
+type passwordPromptModel struct {
+	label     string
+	input     textinput.Model
+	confirmed bool
+}
+
+// NewPasswordInput creates a masked text input for entering a password
+func NewPasswordInput() textinput.Model {
+	input := newSetupInput("password")
+	input.EchoMode = textinput.EchoPassword
+	input.EchoCharacter = '•'
+	input.Focus()
+	return input
+}
+
+func (m passwordPromptModel) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (m passwordPromptModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			return m, tea.Quit
+		case "enter":
+			m.confirmed = true
+			return m, tea.Quit
+		}
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+func (m passwordPromptModel) View() string {
+	return "\n" + inputLabelStyle.Width(0).Render(m.label) + "\n" + inputStyle.Render(m.input.View()) + "\n\n" +
+		infoStyle.Render("Enter: Connect • Esc: Quit") + "\n"
+}
+
+// PromptPassword asks for the password of a profile that does not store it
+func PromptPassword(profile string) (string, error) {
+	m := passwordPromptModel{
+		label: fmt.Sprintf("Password for %s:", profile),
+		input: NewPasswordInput(),
+	}
+
+	finalModel, err := tea.NewProgram(m).Run()
+	if err != nil {
+		return "", err
+	}
+	m, ok := finalModel.(passwordPromptModel)
+	if !ok {
+		return "", fmt.Errorf("could not convert model")
+	}
+	if !m.confirmed {
+		return "", fmt.Errorf("password prompt cancelled")
+	}
+	return m.input.Value(), nil
+}
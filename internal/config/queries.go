@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// SavedQuery is a named statement from the saved queries file. It may use
+// $1-style or :name parameters, which are asked for before it runs.
+type SavedQuery struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	SQL         string `json:"sql"`
+}
+
+// SavedQueries is the contents of the saved queries file
+type SavedQueries struct {
+	Queries []SavedQuery `json:"queries"`
+}
+
+// SavedQueriesPath returns the location of the saved queries file:
+// GO_DOT_DOT_QUERIES when set, so a team can point it at a shared file, and
+// queries.json in the config directory otherwise
+func SavedQueriesPath() (string, error) {
+	if path := os.Getenv("GO_DOT_DOT_QUERIES"); path != "" {
+		return path, nil
+	}
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "queries.json"), nil
+}
+
+// LoadSavedQueries reads the saved queries file. A missing file yields no
+// queries.
+func LoadSavedQueries() ([]SavedQuery, error) {
+	path, err := SavedQueriesPath()
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading saved queries: %w", err)
+	}
+
+	var queries SavedQueries
+	if err := json.Unmarshal(content, &queries); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", path, err)
+	}
+	return queries.Queries, nil
+}
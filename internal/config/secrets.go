@@ -0,0 +1,283 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// secretService is the service name passwords are filed under in OS secret stores
+const secretService = "go-dot-dot"
+
+// Secret store backend names, as recorded in profiles
+const (
+	StoreKeychain      = "keychain"
+	StoreSecretService = "secret-service"
+	StoreFile          = "file"
+)
+
+// ErrSecretNotFound is returned when a secret store has no entry for an account
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretStore keeps connection passwords out of plain-text configuration
+type SecretStore interface {
+	Name() string
+	Get(account string) (string, error)
+	Set(account, secret string) error
+	Delete(account string) error
+}
+
+// DefaultSecretStore picks the best secret store for this machine: the macOS
+// keychain, the Secret Service (GNOME Keyring, KWallet) on Linux desktops, or
+// a file otherwise. GO_DOT_DOT_SECRET_STORE overrides the choice.
+func DefaultSecretStore() (SecretStore, error) {
+	if name := os.Getenv("GO_DOT_DOT_SECRET_STORE"); name != "" {
+		return OpenSecretStore(name)
+	}
+	if runtime.GOOS == "darwin" && hasCommand("security") {
+		return keychainStore{}, nil
+	}
+	if runtime.GOOS == "linux" && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" && hasCommand("secret-tool") {
+		return secretServiceStore{}, nil
+	}
+	return newFileSecretStore()
+}
+
+// OpenSecretStore returns the secret store backend with the given name
+func OpenSecretStore(name string) (SecretStore, error) {
+	switch name {
+	case StoreKeychain:
+		return keychainStore{}, nil
+	case StoreSecretService:
+		return secretServiceStore{}, nil
+	case StoreFile:
+		return newFileSecretStore()
+	default:
+		return nil, fmt.Errorf("unknown secret store %q", name)
+	}
+}
+
+// hasCommand reports whether an executable is on the PATH
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// keychainStore keeps secrets in the macOS login keychain
+type keychainStore struct{}
+
+func (keychainStore) Name() string { return StoreKeychain }
+
+func (keychainStore) Get(account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password", "-s", secretService, "-a", account, "-w").Output()
+	if err != nil {
+		return "", ErrSecretNotFound
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (keychainStore) Set(account, secret string) error {
+	if strings.ContainsAny(secret, "\r\n") {
+		return errors.New("keychain: passwords with line breaks cannot be stored")
+	}
+	// security -i reads commands from stdin, which keeps the password out of
+	// the process list without security prompting on the terminal
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		shellQuote(secretService), shellQuote(account), shellQuote(secret)))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("keychain: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// shellQuote quotes a word for the command line security -i reads
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+func (keychainStore) Delete(account string) error {
+	return exec.Command("security", "delete-generic-password", "-s", secretService, "-a", account).Run()
+}
+
+// secretServiceStore keeps secrets in the freedesktop Secret Service through
+// secret-tool, which reads the secret from stdin
+type secretServiceStore struct{}
+
+func (secretServiceStore) Name() string { return StoreSecretService }
+
+func (secretServiceStore) Get(account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup", "service", secretService, "account", account).Output()
+	if err != nil || len(out) == 0 {
+		return "", ErrSecretNotFound
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+func (secretServiceStore) Set(account, secret string) error {
+	cmd := exec.Command("secret-tool", "store", "--label", secretService+" "+account,
+		"service", secretService, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (secretServiceStore) Delete(account string) error {
+	return exec.Command("secret-tool", "clear", "service", secretService, "account", account).Run()
+}
+
+// fileSecretStore keeps secrets in a file in the config directory, for
+// headless machines without an OS secret store. They are encrypted with
+// AES-GCM under a key derived from GO_DOT_DOT_PASSPHRASE; without a
+// passphrase the store refuses to read or write anything.
+type fileSecretStore struct {
+	path string
+}
+
+// encryptedSecrets is the on-disk format of the secrets file
+type encryptedSecrets struct {
+	Salt  []byte `json:"salt"`
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// errNoPassphrase is returned by the file store when GO_DOT_DOT_PASSPHRASE is
+// not set
+var errNoPassphrase = errors.New("set GO_DOT_DOT_PASSPHRASE to keep passwords in an encrypted file")
+
+func newFileSecretStore() (*fileSecretStore, error) {
+	dir, err := Dir()
+	if err != nil {
+		return nil, err
+	}
+	return &fileSecretStore{path: filepath.Join(dir, "secrets.enc")}, nil
+}
+
+func (s *fileSecretStore) Name() string { return StoreFile }
+
+// Usable reports whether there is a passphrase to encrypt secrets with
+func (s *fileSecretStore) Usable() bool {
+	return os.Getenv("GO_DOT_DOT_PASSPHRASE") != ""
+}
+
+func (s *fileSecretStore) Get(account string) (string, error) {
+	secrets, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	secret, ok := secrets[account]
+	if !ok {
+		return "", ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func (s *fileSecretStore) Set(account, secret string) error {
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[account] = secret
+	return s.save(secrets)
+}
+
+func (s *fileSecretStore) Delete(account string) error {
+	secrets, err := s.load()
+	if err != nil {
+		return err
+	}
+	delete(secrets, account)
+	return s.save(secrets)
+}
+
+// load decrypts the secrets file; a missing file holds no secrets
+func (s *fileSecretStore) load() (map[string]string, error) {
+	if !s.Usable() {
+		return nil, errNoPassphrase
+	}
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file encryptedSecrets
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("corrupt secrets file %s: %w", s.path, err)
+	}
+	gcm, err := s.cipher(file.Salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, file.Nonce, file.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt %s; check GO_DOT_DOT_PASSPHRASE", s.path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, fmt.Errorf("corrupt secrets file %s: %w", s.path, err)
+	}
+	return secrets, nil
+}
+
+// save encrypts the secrets with a fresh salt and nonce and writes them
+func (s *fileSecretStore) save(secrets map[string]string) error {
+	if !s.Usable() {
+		return errNoPassphrase
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	file := encryptedSecrets{Salt: make([]byte, 16)}
+	if _, err := rand.Read(file.Salt); err != nil {
+		return err
+	}
+	gcm, err := s.cipher(file.Salt)
+	if err != nil {
+		return err
+	}
+	file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(file.Nonce); err != nil {
+		return err
+	}
+	file.Data = gcm.Seal(nil, file.Nonce, plain, nil)
+
+	content, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, content, 0600)
+}
+
+// cipher derives the AES-256-GCM cipher for a salt from the passphrase
+func (s *fileSecretStore) cipher(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(os.Getenv("GO_DOT_DOT_PASSPHRASE")), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
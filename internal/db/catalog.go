@@ -0,0 +1,363 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+// ErrTableNotFound is returned by FindTable for an unknown relation
+var ErrTableNotFound = errors.New("relation not found")
+
+// FindTable looks up a browsable relation by name. The name may be schema
+// qualified; otherwise it is resolved through the search_path.
+func (db *Database) FindTable(ctx context.Context, name string) (model.TableItem, error) {
+	var table model.TableItem
+	err := db.pool.QueryRow(ctx, `
+        SELECT n.nspname, c.relname, c.relkind::text
+        FROM pg_catalog.pg_class c
+        JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+        WHERE c.oid = to_regclass($1)
+          AND c.relkind IN ('r', 'v', 'm', 'f', 'p');
+    `, name).Scan(&table.Schema, &table.Name, &table.Kind)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return table, fmt.Errorf("%w: %s", ErrTableNotFound, name)
+	}
+	return table, err
+}
+
+// exactCountThreshold is the estimated row count above which CountRows
+// reports the planner estimate instead of running count(*)
+const exactCountThreshold = 100000
+
+// FetchRowKey returns the columns that identify a row of a relation, in key
+// order: the primary key, or else the narrowest unique index over NOT NULL
+// columns without a predicate or expressions. Relations without such a key
+// (including views) return an empty slice.
+func (db *Database) FetchRowKey(ctx context.Context, table model.TableItem) ([]string, error) {
+	rows, err := db.pool.Query(ctx, `
+        WITH key AS (
+            SELECT i.indrelid, (i.indkey::int2[])[0:i.indnkeyatts - 1] AS attnums
+            FROM pg_catalog.pg_index i
+            WHERE i.indrelid = $1::regclass
+              AND i.indisunique AND i.indisvalid
+              AND i.indpred IS NULL AND i.indexprs IS NULL
+              AND NOT EXISTS (
+                  SELECT 1 FROM pg_catalog.pg_attribute a
+                  WHERE a.attrelid = i.indrelid
+                    AND a.attnum = ANY((i.indkey::int2[])[0:i.indnkeyatts - 1])
+                    AND NOT a.attnotnull)
+            ORDER BY i.indisprimary DESC, i.indnkeyatts, i.indexrelid
+            LIMIT 1
+        )
+        SELECT a.attname
+        FROM key
+        JOIN pg_catalog.pg_attribute a
+          ON a.attrelid = key.indrelid AND a.attnum = ANY(key.attnums)
+        ORDER BY array_position(key.attnums, a.attnum);
+    `, table.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// FetchColumns returns the columns of a relation as information_schema
+// describes them, in table order
+func (db *Database) FetchColumns(ctx context.Context, table model.TableItem) ([]model.Column, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT column_name,
+               CASE data_type
+                   WHEN 'USER-DEFINED' THEN udt_name
+                   WHEN 'ARRAY' THEN ltrim(udt_name, '_') || '[]'
+                   ELSE data_type
+               END || COALESCE('(' || character_maximum_length || ')', ''),
+               COALESCE(column_default, ''),
+               is_nullable = 'YES',
+               is_identity = 'YES',
+               COALESCE(identity_generation = 'ALWAYS', false) OR is_generated = 'ALWAYS'
+        FROM information_schema.columns
+        WHERE table_schema = $1 AND table_name = $2
+        ORDER BY ordinal_position;
+    `, table.Schema, table.Name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []model.Column
+	for rows.Next() {
+		var c model.Column
+		if err := rows.Scan(&c.Name, &c.Type, &c.Default, &c.Nullable, &c.Identity, &c.Generated); err != nil {
+			return nil, err
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// FetchColumnDetails returns the columns of a relation from the system
+// catalogs, with the details shown in the structure tab
+func (db *Database) FetchColumnDetails(ctx context.Context, table model.TableItem) ([]model.ColumnDetail, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT a.attname,
+               pg_catalog.format_type(a.atttypid, a.atttypmod),
+               COALESCE(pg_catalog.pg_get_expr(d.adbin, d.adrelid), ''),
+               a.attnotnull,
+               CASE a.attidentity WHEN 'a' THEN 'ALWAYS' WHEN 'd' THEN 'BY DEFAULT' ELSE '' END,
+               a.attgenerated = 's',
+               COALESCE(co.collname, ''),
+               COALESCE(pg_catalog.col_description(a.attrelid, a.attnum), '')
+        FROM pg_catalog.pg_attribute a
+        JOIN pg_catalog.pg_type t ON t.oid = a.atttypid
+        LEFT JOIN pg_catalog.pg_attrdef d ON d.adrelid = a.attrelid AND d.adnum = a.attnum
+        LEFT JOIN pg_catalog.pg_collation co
+          ON co.oid = a.attcollation AND a.attcollation <> t.typcollation
+        WHERE a.attrelid = $1::regclass AND a.attnum > 0 AND NOT a.attisdropped
+        ORDER BY a.attnum;
+    `, table.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []model.ColumnDetail
+	for rows.Next() {
+		var c model.ColumnDetail
+		var generated bool
+		if err := rows.Scan(&c.Name, &c.Type, &c.Default, &c.NotNull, &c.Identity, &generated, &c.Collation, &c.Comment); err != nil {
+			return nil, err
+		}
+		if generated {
+			// For generated columns pg_attrdef holds the generation expression
+			c.Generated, c.Default = c.Default, ""
+		}
+		columns = append(columns, c)
+	}
+	return columns, rows.Err()
+}
+
+// FetchIndexes returns the indexes of a relation with their size and the
+// usage counts from pg_stat_user_indexes
+func (db *Database) FetchIndexes(ctx context.Context, table model.TableItem) ([]model.IndexInfo, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT c.relname,
+               pg_catalog.pg_get_indexdef(i.indexrelid),
+               pg_catalog.pg_size_pretty(pg_catalog.pg_relation_size(i.indexrelid)),
+               i.indisprimary, i.indisunique, i.indpred IS NOT NULL, i.indisvalid,
+               COALESCE(s.idx_scan, 0), COALESCE(s.idx_tup_read, 0), COALESCE(s.idx_tup_fetch, 0)
+        FROM pg_catalog.pg_index i
+        JOIN pg_catalog.pg_class c ON c.oid = i.indexrelid
+        LEFT JOIN pg_catalog.pg_stat_user_indexes s ON s.indexrelid = i.indexrelid
+        WHERE i.indrelid = $1::regclass
+        ORDER BY i.indisprimary DESC, c.relname;
+    `, table.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []model.IndexInfo
+	for rows.Next() {
+		var i model.IndexInfo
+		if err := rows.Scan(&i.Name, &i.Definition, &i.Size, &i.Primary, &i.Unique, &i.Partial, &i.Valid,
+			&i.Scans, &i.TuplesRead, &i.TuplesFetched); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, rows.Err()
+}
+
+// constraintTypes names the pg_constraint.contype codes
+var constraintTypes = map[string]string{
+	"p": "PRIMARY KEY",
+	"f": "FOREIGN KEY",
+	"u": "UNIQUE",
+	"c": "CHECK",
+	"x": "EXCLUDE",
+	"t": "CONSTRAINT TRIGGER",
+	"n": "NOT NULL",
+}
+
+// FetchConstraints returns the constraints of a relation with their
+// definitions, primary key first
+func (db *Database) FetchConstraints(ctx context.Context, table model.TableItem) ([]model.ConstraintInfo, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT conname, contype::text, pg_catalog.pg_get_constraintdef(oid, true)
+        FROM pg_catalog.pg_constraint
+        WHERE conrelid = $1::regclass
+        ORDER BY array_position(ARRAY['p', 'f', 'u', 'c', 'x', 't', 'n'], contype::text), conname;
+    `, table.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var constraints []model.ConstraintInfo
+	for rows.Next() {
+		var c model.ConstraintInfo
+		if err := rows.Scan(&c.Name, &c.Type, &c.Definition); err != nil {
+			return nil, err
+		}
+		if name, ok := constraintTypes[c.Type]; ok {
+			c.Type = name
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints, rows.Err()
+}
+
+// triggerStates names the pg_trigger.tgenabled codes
+var triggerStates = map[string]string{
+	"O": "enabled",
+	"D": "disabled",
+	"R": "replica",
+	"A": "always",
+}
+
+// FetchTriggers returns the user-defined triggers of a relation with the
+// functions they call
+func (db *Database) FetchTriggers(ctx context.Context, table model.TableItem) ([]model.TriggerInfo, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT t.tgname, t.tgenabled::text, t.tgfoid::regprocedure::text,
+               pg_catalog.pg_get_triggerdef(t.oid, true)
+        FROM pg_catalog.pg_trigger t
+        WHERE t.tgrelid = $1::regclass AND NOT t.tgisinternal
+        ORDER BY t.tgname;
+    `, table.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var triggers []model.TriggerInfo
+	for rows.Next() {
+		var t model.TriggerInfo
+		if err := rows.Scan(&t.Name, &t.Enabled, &t.Function, &t.Definition); err != nil {
+			return nil, err
+		}
+		if state, ok := triggerStates[t.Enabled]; ok {
+			t.Enabled = state
+		}
+		triggers = append(triggers, t)
+	}
+	return triggers, rows.Err()
+}
+
+// FetchForeignKeys returns the foreign keys of a relation: the references
+// it makes to other relations
+func (db *Database) FetchForeignKeys(ctx context.Context, table model.TableItem) ([]model.ForeignKey, error) {
+	return db.fetchForeignKeys(ctx, "c.conrelid", table)
+}
+
+// FetchReferencingKeys returns the foreign keys of other relations that
+// reference this one
+func (db *Database) FetchReferencingKeys(ctx context.Context, table model.TableItem) ([]model.ForeignKey, error) {
+	return db.fetchForeignKeys(ctx, "c.confrelid", table)
+}
+
+// fetchForeignKeys returns the foreign keys whose referencing or referenced
+// relation, as picked by the column, is the given one
+func (db *Database) fetchForeignKeys(ctx context.Context, relation string, table model.TableItem) ([]model.ForeignKey, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT c.conname,
+               tn.nspname, t.relname, t.relkind::text,
+               ARRAY(SELECT a.attname::text
+                     FROM unnest(c.conkey) WITH ORDINALITY k(attnum, n)
+                     JOIN pg_catalog.pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = k.attnum
+                     ORDER BY k.n),
+               rn.nspname, r.relname, r.relkind::text,
+               ARRAY(SELECT a.attname::text
+                     FROM unnest(c.confkey) WITH ORDINALITY k(attnum, n)
+                     JOIN pg_catalog.pg_attribute a ON a.attrelid = c.confrelid AND a.attnum = k.attnum
+                     ORDER BY k.n)
+        FROM pg_catalog.pg_constraint c
+        JOIN pg_catalog.pg_class t ON t.oid = c.conrelid
+        JOIN pg_catalog.pg_namespace tn ON tn.oid = t.relnamespace
+        JOIN pg_catalog.pg_class r ON r.oid = c.confrelid
+        JOIN pg_catalog.pg_namespace rn ON rn.oid = r.relnamespace
+        WHERE c.contype = 'f' AND `+relation+` = $1::regclass
+        ORDER BY tn.nspname, t.relname, c.conname;
+    `, table.Identifier())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.ForeignKey
+	for rows.Next() {
+		var fk model.ForeignKey
+		if err := rows.Scan(&fk.Name,
+			&fk.Table.Schema, &fk.Table.Name, &fk.Table.Kind, &fk.Columns,
+			&fk.RefTable.Schema, &fk.RefTable.Name, &fk.RefTable.Kind, &fk.RefColumns); err != nil {
+			return nil, err
+		}
+		keys = append(keys, fk)
+	}
+	return keys, rows.Err()
+}
+
+// exactCountTimeout bounds the count(*) CountRows runs when the planner has
+// no usable estimate
+const exactCountTimeout = "2s"
+
+// queryCanceled is the SQLSTATE of a statement stopped by statement_timeout
+// or a cancel request
+const queryCanceled = "57014"
+
+// CountRows returns the number of rows in a relation. Small tables are counted
+// exactly; large ones report the planner's estimate with exact set to false.
+// Relations the planner has no estimate for (never analyzed, or partitioned
+// parents) are counted with a statement timeout and return -1 when it
+// expires. Views and foreign tables are not counted and return -1.
+func (db *Database) CountRows(ctx context.Context, table model.TableItem) (count int64, exact bool, err error) {
+	if table.Kind == model.KindView || table.Kind == model.KindForeignTable {
+		return -1, false, nil
+	}
+
+	var estimate float64
+	err = db.pool.QueryRow(ctx, `
+        SELECT reltuples FROM pg_catalog.pg_class WHERE oid = $1::regclass;
+    `, table.Identifier()).Scan(&estimate)
+	if err != nil {
+		return -1, false, err
+	}
+	if estimate > exactCountThreshold {
+		return int64(estimate), false, nil
+	}
+
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return -1, false, err
+	}
+	defer tx.Rollback(context.Background())
+	if _, err := tx.Exec(ctx, "SET LOCAL statement_timeout = '"+exactCountTimeout+"'"); err != nil {
+		return -1, false, err
+	}
+	err = tx.QueryRow(ctx, "SELECT count(*) FROM "+table.Identifier()).Scan(&count)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == queryCanceled && ctx.Err() == nil {
+		// Too big to count quickly and not analyzed yet
+		return -1, false, nil
+	}
+	if err != nil {
+		return -1, false, err
+	}
+	return count, true, nil
+}
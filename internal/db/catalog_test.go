@@ -0,0 +1,72 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ddoemonn/go-dot-dot/internal/config"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+// testDatabase connects to the server GO_DOT_DOT_TEST_DSN names, skipping
+// the test when it isn't set, and creates a schema for the test to use
+func testDatabase(t *testing.T) (*Database, string) {
+	t.Helper()
+	dsn := os.Getenv("GO_DOT_DOT_TEST_DSN")
+	if dsn == "" {
+		t.Skip("set GO_DOT_DOT_TEST_DSN to run tests against a PostgreSQL server")
+	}
+	ctx := context.Background()
+	db, err := Connect(ctx, &config.DBConfig{DSN: dsn})
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := fmt.Sprintf("go_dot_dot_test_%d", time.Now().UnixNano())
+	if _, err := db.pool.Exec(ctx, "CREATE SCHEMA "+schema); err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.pool.Exec(context.Background(), "DROP SCHEMA "+schema+" CASCADE")
+		db.Close()
+	})
+	return db, schema
+}
+
+func TestFetchColumns(t *testing.T) {
+	db, schema := testDatabase(t)
+	ctx := context.Background()
+	_, err := db.pool.Exec(ctx, `CREATE TABLE `+schema+`.items (
+		id integer GENERATED ALWAYS AS IDENTITY,
+		seq integer GENERATED BY DEFAULT AS IDENTITY,
+		name varchar(20) NOT NULL,
+		price numeric DEFAULT 0,
+		total numeric GENERATED ALWAYS AS (price * 2) STORED
+	)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	columns, err := db.FetchColumns(ctx, model.TableItem{Schema: schema, Name: "items"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []model.Column{
+		{Name: "id", Type: "integer", Identity: true, Generated: true},
+		{Name: "seq", Type: "integer", Identity: true},
+		{Name: "name", Type: "character varying(20)"},
+		{Name: "price", Type: "numeric", Default: "0", Nullable: true},
+		{Name: "total", Type: "numeric", Nullable: true, Generated: true},
+	}
+	if len(columns) != len(want) {
+		t.Fatalf("FetchColumns() = %+v, want %+v", columns, want)
+	}
+	for i := range want {
+		if columns[i] != want[i] {
+			t.Errorf("column %d = %+v, want %+v", i, columns[i], want[i])
+		}
+	}
+}
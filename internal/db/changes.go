@@ -0,0 +1,227 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+// ErrConflict is returned by ApplyChanges when a row was changed or deleted
+// by someone else after it was loaded
+var ErrConflict = errors.New("row changed since it was loaded")
+
+// ChangeKind is the statement a staged change becomes
+type ChangeKind int
+
+const (
+	ChangeInsert ChangeKind = iota
+	ChangeUpdate
+	ChangeDelete
+)
+
+// Change is one insert, update or delete staged in a changeset, applied
+// together with the rest by ApplyChanges
+type Change struct {
+	Kind    ChangeKind
+	Table   model.TableItem
+	Key     RowKey    // Row to update or delete, as it was loaded
+	Columns []string  // Columns to insert or set
+	Values  []*string // Their new values in text form; nil is NULL
+
+	// The row as it was loaded, compared with the stored row before an update
+	// or delete is applied
+	LoadedColumns []string
+	Loaded        []*string
+}
+
+// ChangeError reports which change of a changeset could not be applied
+type ChangeError struct {
+	Index int
+	Err   error
+}
+
+func (e *ChangeError) Error() string {
+	return fmt.Sprintf("change %d: %v", e.Index+1, e.Err)
+}
+
+func (e *ChangeError) Unwrap() error {
+	return e.Err
+}
+
+// Set adds a column to an update, or replaces the value it already sets
+func (c *Change) Set(column string, value *string) {
+	for i, name := range c.Columns {
+		if name == column {
+			c.Values[i] = value
+			return
+		}
+	}
+	c.Columns = append(c.Columns, column)
+	c.Values = append(c.Values, value)
+}
+
+// Value returns the value a change gives a column, and whether it sets it
+func (c Change) Value(column string) (*string, bool) {
+	for i, name := range c.Columns {
+		if name == column {
+			return c.Values[i], true
+		}
+	}
+	return nil, false
+}
+
+// SQL renders the change as a statement with its values inlined, for
+// reviewing before it is applied
+func (c Change) SQL() string {
+	switch c.Kind {
+	case ChangeInsert:
+		if len(c.Columns) == 0 {
+			return fmt.Sprintf("INSERT INTO %s DEFAULT VALUES;", c.Table.Identifier())
+		}
+		values := make([]string, len(c.Values))
+		for i, value := range c.Values {
+			values[i] = quoteLiteral(value)
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s);", c.Table.Identifier(), quoteIdents(c.Columns), strings.Join(values, ", "))
+	case ChangeUpdate:
+		assignments := make([]string, len(c.Columns))
+		for i, column := range c.Columns {
+			assignments[i] = pgx.Identifier{column}.Sanitize() + " = " + quoteLiteral(c.Values[i])
+		}
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s;", c.Table.Identifier(), strings.Join(assignments, ", "), c.Key.literal())
+	default:
+		return fmt.Sprintf("DELETE FROM %s WHERE %s;", c.Table.Identifier(), c.Key.literal())
+	}
+}
+
+// statement returns the change as a parameterized statement
+func (c Change) statement() (string, []any) {
+	args := make([]any, 0, len(c.Values)+len(c.Key.Values))
+	placeholders := make([]string, len(c.Values))
+	for i, value := range c.Values {
+		var arg any
+		if value != nil {
+			arg = *value
+		}
+		args = append(args, arg)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+
+	switch c.Kind {
+	case ChangeInsert:
+		if len(c.Columns) == 0 {
+			return "INSERT INTO " + c.Table.Identifier() + " DEFAULT VALUES", nil
+		}
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", c.Table.Identifier(), quoteIdents(c.Columns), strings.Join(placeholders, ", ")), args
+	case ChangeUpdate:
+		assignments := make([]string, len(c.Columns))
+		for i, column := range c.Columns {
+			assignments[i] = pgx.Identifier{column}.Sanitize() + " = " + placeholders[i]
+		}
+		where, args := c.Key.whereClause(args)
+		return fmt.Sprintf("UPDATE %s SET %s WHERE %s", c.Table.Identifier(), strings.Join(assignments, ", "), where), args
+	default:
+		where, args := c.Key.whereClause(args)
+		return fmt.Sprintf("DELETE FROM %s WHERE %s", c.Table.Identifier(), where), args
+	}
+}
+
+// ApplyChanges applies a changeset in one transaction and returns the number
+// of rows changed. Rows to update or delete are locked and compared with the
+// values they were loaded with first; if any differ, or a statement fails,
+// nothing is applied and the returned ChangeError says which change failed.
+func (db *Database) ApplyChanges(ctx context.Context, changes []Change) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	tx, err := db.pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// Rolling back after a commit does nothing
+	defer tx.Rollback(context.Background())
+
+	var total int64
+	for i, change := range changes {
+		if change.Kind != ChangeInsert {
+			if err := checkLoaded(ctx, tx, change); err != nil {
+				return 0, &ChangeError{Index: i, Err: err}
+			}
+		}
+		query, args := change.statement()
+		tag, err := tx.Exec(ctx, query, args...)
+		if err != nil {
+			return 0, &ChangeError{Index: i, Err: err}
+		}
+		total += tag.RowsAffected()
+	}
+	return total, tx.Commit(ctx)
+}
+
+// checkLoaded locks the row a change applies to and makes sure it still
+// holds the values it was loaded with. Values are compared in the server's
+// text form, as they were loaded.
+func checkLoaded(ctx context.Context, tx pgx.Tx, change Change) error {
+	if len(change.Key.Columns) == 0 {
+		return fmt.Errorf("%s has no primary key or unique key to identify rows by", change.Table.QualifiedName())
+	}
+	where, args := change.Key.whereClause(nil)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s FOR UPDATE", quoteIdents(change.LoadedColumns), change.Table.Identifier(), where)
+	rows, err := tx.Query(ctx, query, append([]any{pgx.QueryResultFormats{pgx.TextFormatCode}}, args...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	found := false
+	var changed []string
+	for rows.Next() {
+		found = true
+		for i, raw := range rows.RawValues() {
+			loaded := change.Loaded[i]
+			if (raw == nil) != (loaded == nil) || (raw != nil && string(raw) != *loaded) {
+				changed = append(changed, change.LoadedColumns[i])
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	switch {
+	case !found:
+		return fmt.Errorf("%w: the row was deleted", ErrConflict)
+	case len(changed) > 0:
+		return fmt.Errorf("%w: %s now differs", ErrConflict, strings.Join(changed, ", "))
+	}
+	return nil
+}
+
+// literal renders the condition matching a row key with its values inlined
+func (k RowKey) literal() string {
+	values := make([]string, len(k.Values))
+	for i := range k.Values {
+		values[i] = quoteLiteral(&k.Values[i])
+	}
+	return fmt.Sprintf("(%s) = (%s)", quoteIdents(k.Columns), strings.Join(values, ", "))
+}
+
+// quoteLiteral renders a text value as an SQL string literal, or NULL. Like
+// quote_literal, values with backslashes become E'...' strings so they read the
+// same whatever standard_conforming_strings is set to.
+func quoteLiteral(value *string) string {
+	if value == nil {
+		return "NULL"
+	}
+	quoted := "'" + strings.ReplaceAll(*value, "'", "''") + "'"
+	if strings.Contains(*value, `\`) {
+		return "E" + strings.ReplaceAll(quoted, `\`, `\\`)
+	}
+	return quoted
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+func text(s string) *string {
+	return &s
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	tests := []struct {
+		name  string
+		value *string
+		want  string
+	}{
+		{"NULL", nil, "NULL"},
+		{"NULL as text", text("NULL"), "'NULL'"},
+		{"empty", text(""), "''"},
+		{"plain", text("abc"), "'abc'"},
+		{"quote", text("it's"), "'it''s'"},
+		{"only quotes", text("''"), "''''''"},
+		{"backslash", text(`C:\dir`), `E'C:\\dir'`},
+		{"backslash and quote", text(`\'`), `E'\\'''`},
+		{"newline", text("a\nb"), "'a\nb'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := quoteLiteral(tt.value); got != tt.want {
+				t.Errorf("quoteLiteral() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangeSQL(t *testing.T) {
+	table := model.TableItem{Schema: "public", Name: "Users"}
+	key := RowKey{Columns: []string{"id", "tenant"}, Values: []string{"1", "o'brien"}}
+	tests := []struct {
+		name   string
+		change Change
+		want   string
+	}{
+		{
+			name:   "insert",
+			change: Change{Kind: ChangeInsert, Table: table, Columns: []string{"name", "note"}, Values: []*string{text("O'Hara"), nil}},
+			want:   `INSERT INTO "public"."Users" ("name", "note") VALUES ('O''Hara', NULL);`,
+		},
+		{
+			name:   "insert of defaults",
+			change: Change{Kind: ChangeInsert, Table: table},
+			want:   `INSERT INTO "public"."Users" DEFAULT VALUES;`,
+		},
+		{
+			name:   "update",
+			change: Change{Kind: ChangeUpdate, Table: table, Key: key, Columns: []string{"path", "note"}, Values: []*string{text(`a\b`), text("NULL")}},
+			want:   `UPDATE "public"."Users" SET "path" = E'a\\b', "note" = 'NULL' WHERE ("id", "tenant") = ('1', 'o''brien');`,
+		},
+		{
+			name:   "update to NULL",
+			change: Change{Kind: ChangeUpdate, Table: table, Key: key, Columns: []string{"note"}, Values: []*string{nil}},
+			want:   `UPDATE "public"."Users" SET "note" = NULL WHERE ("id", "tenant") = ('1', 'o''brien');`,
+		},
+		{
+			name:   "quoted column",
+			change: Change{Kind: ChangeUpdate, Table: table, Key: key, Columns: []string{`we"ird`}, Values: []*string{text("x")}},
+			want:   `UPDATE "public"."Users" SET "we""ird" = 'x' WHERE ("id", "tenant") = ('1', 'o''brien');`,
+		},
+		{
+			name:   "delete",
+			change: Change{Kind: ChangeDelete, Table: table, Key: RowKey{Columns: []string{"id"}, Values: []string{`\x`}}},
+			want:   `DELETE FROM "public"."Users" WHERE ("id") = (E'\\x');`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.change.SQL(); got != tt.want {
+				t.Errorf("SQL() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,28 +2,78 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/ddoemonn/go-dot-dot/internal/config"
-	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgconn/ctxwatch"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// cancelDeadlineDelay is how long a cancelled query may take to stop on the
+// server before its connection is dropped
+const cancelDeadlineDelay = 5 * time.Second
+
+// ErrConnect wraps every error from Connect, so callers can tell a failed
+// connection apart from other startup errors
+var ErrConnect = errors.New("failed to connect to database")
+
+// ErrReadOnly is returned by the methods that change data on a read-only
+// connection
+var ErrReadOnly = errors.New("the connection is read-only")
+
 // Database represents a database connection
 type Database struct {
-	pool *pgxpool.Pool
+	pool       *pgxpool.Pool
+	formatters map[uint32]Formatter
+	readOnly   bool
 }
 
-// Connect establishes a connection to the database
-func Connect(cfg *config.DBConfig) (*Database, error) {
+// Connect establishes a connection to the database and verifies it is reachable
+func Connect(ctx context.Context, cfg *config.DBConfig) (*Database, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.ConnectionString())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConnect, err)
+	}
+	// Cancelling a query's context sends a cancel request to the server
+	// (like pg_cancel_backend) instead of just dropping the connection
+	poolConfig.ConnConfig.BuildContextWatcherHandler = func(conn *pgconn.PgConn) ctxwatch.Handler {
+		return &pgconn.CancelRequestContextWatcherHandler{
+			Conn:          conn,
+			DeadlineDelay: cancelDeadlineDelay,
+		}
+	}
+
+	if cfg.ReadOnly {
+		poolConfig.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+
 	// Connect to the database
-	pool, err := pgxpool.New(context.Background(), cfg.ConnectionString())
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrConnect, err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("%w: %w", ErrConnect, err)
 	}
 
-	return &Database{pool: pool}, nil
+	db := &Database{pool: pool, readOnly: cfg.ReadOnly}
+	if err := db.loadFormatters(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("%w: %w", ErrConnect, err)
+	}
+	return db, nil
+}
+
+// ReadOnly reports whether the connection was opened read-only
+func (db *Database) ReadOnly() bool {
+	return db.readOnly
 }
 
 // Close closes the database connection
@@ -38,13 +88,18 @@ func (db *Database) GetPool() *pgxpool.Pool {
 	return db.pool
 }
 
-// FetchTables retrieves all tables from the database
-func (db *Database) FetchTables() ([]string, error) {
-	var tables []string
-	rows, err := db.pool.Query(context.Background(), `
-        SELECT tablename FROM pg_catalog.pg_tables 
-        WHERE schemaname NOT IN ('pg_catalog', 'information_schema')
-        ORDER BY tablename;
+// FetchTables retrieves all browsable relations (tables, views, materialized
+// views, foreign tables and partitioned tables), ordered by schema and name
+func (db *Database) FetchTables(ctx context.Context) ([]model.TableItem, error) {
+	var tables []model.TableItem
+	rows, err := db.pool.Query(ctx, `
+        SELECT n.nspname, c.relname, c.relkind::text
+        FROM pg_catalog.pg_class c
+        JOIN pg_catalog.pg_namespace n ON n.oid = c.relnamespace
+        WHERE c.relkind IN ('r', 'v', 'm', 'f', 'p')
+          AND n.nspname <> 'information_schema'
+          AND n.nspname !~ '^pg_'
+        ORDER BY n.nspname, c.relname;
     `)
 	if err != nil {
 		return nil, err
@@ -52,22 +107,117 @@ func (db *Database) FetchTables() ([]string, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var tableName string
-		err := rows.Scan(&tableName)
+		var table model.TableItem
+		err := rows.Scan(&table.Schema, &table.Name, &table.Kind)
 		if err != nil {
 			return nil, err
 		}
-		tables = append(tables, tableName)
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// RefreshMaterializedView re-runs the query backing a materialized view
+func (db *Database) RefreshMaterializedView(ctx context.Context, view model.TableItem) error {
+	if view.Kind != model.KindMaterializedView {
+		return fmt.Errorf("%s is not a materialized view", view.QualifiedName())
 	}
-	return tables, nil
+	if db.readOnly {
+		return ErrReadOnly
+	}
+	_, err := db.pool.Exec(ctx, "REFRESH MATERIALIZED VIEW "+view.Identifier())
+	return err
+}
+
+// PageSize is the number of rows fetched per page of table data
+const PageSize = 500
+
+// PageRequest describes which page of a relation to fetch. When KeyColumns is
+// set and no Sort is requested the page is fetched by keyset (rows after the
+// After key), otherwise by OFFSET.
+type PageRequest struct {
+	KeyColumns []string
+	After      []any
+	Offset     int
+	Limit      int
+	Filter     *SearchFilter
+	Match      *model.RowMatch
+	Sort       []model.SortKey
+}
+
+// SearchFilter restricts a page to rows where any of the columns, cast to
+// text, contains the pattern (case-insensitive)
+type SearchFilter struct {
+	Columns []string
+	Pattern string
+}
+
+// Page holds one page of table data
+type Page struct {
+	Columns []string
+	Rows    [][]model.Cell
+	LastKey []any // Key column values of the last row, for keyset paging
+	HasMore bool
 }
 
-// FetchTableData retrieves data from a specific table
-func (db *Database) FetchTableData(tableName string) ([][]string, []string, error) {
-	query := fmt.Sprintf("SELECT * FROM \"%s\" LIMIT 1000", tableName) // Added limit for performance
-	rows, err := db.pool.Query(context.Background(), query)
+// FetchTableData retrieves one page of data from a specific relation
+func (db *Database) FetchTableData(ctx context.Context, table model.TableItem, req PageRequest) (*Page, error) {
+	if req.Limit <= 0 {
+		req.Limit = PageSize
+	}
+
+	var args []any
+	var conditions []string
+	if req.Filter != nil && len(req.Filter.Columns) > 0 {
+		args = append(args, "%"+escapeLike(req.Filter.Pattern)+"%")
+		matches := make([]string, len(req.Filter.Columns))
+		for i, column := range req.Filter.Columns {
+			matches[i] = fmt.Sprintf("%s::text ILIKE $%d", pgx.Identifier{column}.Sanitize(), len(args))
+		}
+		conditions = append(conditions, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	if req.Match != nil {
+		var condition string
+		condition, args = RowKey{Columns: req.Match.Columns, Values: req.Match.Values}.whereClause(args)
+		conditions = append(conditions, condition)
+	}
+
+	keyList := quoteIdents(req.KeyColumns)
+	keyset := len(req.KeyColumns) > 0 && len(req.Sort) == 0
+	if keyset && len(req.After) == len(req.KeyColumns) {
+		placeholders := make([]string, len(req.After))
+		for i := range req.After {
+			args = append(args, req.After[i])
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, fmt.Sprintf("(%s) > (%s)", keyList, strings.Join(placeholders, ", ")))
+	}
+
+	query := "SELECT * FROM " + table.Identifier()
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	// Fetch one extra row to find out whether another page exists
+	switch {
+	case keyset:
+		query += fmt.Sprintf(" ORDER BY %s LIMIT %d", keyList, req.Limit+1)
+	case len(req.Sort) > 0:
+		// The key columns break ties so OFFSET pages stay stable
+		orderBy := orderByClause(req.Sort)
+		if len(req.KeyColumns) > 0 {
+			orderBy += ", " + keyList
+		}
+		query += fmt.Sprintf(" ORDER BY %s LIMIT %d OFFSET %d", orderBy, req.Limit+1, req.Offset)
+	default:
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", req.Limit+1, req.Offset)
+	}
+
+	// Request every column in text format so values can be shown losslessly
+	args = append([]any{pgx.QueryResultFormats{pgx.TextFormatCode}}, args...)
+	rows, err := db.pool.Query(ctx, query, args...)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -80,49 +230,128 @@ func (db *Database) FetchTableData(tableName string) ([][]string, []string, erro
 		columnOIDs[i] = fd.DataTypeOID
 	}
 
+	// Locate the key columns in the result so the last key can be captured
+	keyIndexes := make([]int, 0, len(req.KeyColumns))
+	for _, keyColumn := range req.KeyColumns {
+		for i, column := range columns {
+			if column == keyColumn {
+				keyIndexes = append(keyIndexes, i)
+				break
+			}
+		}
+	}
+
 	// Fetch rows
-	var data [][]string
+	page := &Page{Columns: columns}
 	for rows.Next() {
-		values, err := rows.Values()
-		if err != nil {
-			return nil, nil, err
+		if len(page.Rows) == req.Limit {
+			page.HasMore = true
+			break
 		}
 
-		row := make([]string, len(values))
-		for i, v := range values {
-			if v == nil {
-				row[i] = "NULL"
-			} else {
-				switch val := v.(type) {
-				case [16]uint8:
-					if columnOIDs[i] == 2950 { // column OID for uuid
-						u, err := uuid.FromBytes(val[:])
-						if err != nil {
-							row[i] = fmt.Sprintf("%v", val)
-						} else {
-							row[i] = u.String()
-						}
-					} else {
-						row[i] = fmt.Sprintf("%v", val)
-					}
-				case pgtype.Numeric:
-					if !val.Valid {
-						row[i] = "NULL"
-						continue
-					}
-					f, err := val.Float64Value()
-					if err != nil {
-						row[i] = "NULL"
-						continue
-					}
-					row[i] = fmt.Sprintf("%f", f.Float64)
-				default:
-					row[i] = fmt.Sprintf("%v", val)
-				}
+		values := rows.RawValues()
+
+		if len(keyIndexes) > 0 {
+			// Key values go back to the server as text-format parameters
+			page.LastKey = make([]any, len(keyIndexes))
+			for k, idx := range keyIndexes {
+				page.LastKey[k] = string(values[idx])
 			}
 		}
-		data = append(data, row)
+
+		row := make([]model.Cell, len(values))
+		for i, v := range values {
+			row[i] = db.makeCell(columnOIDs[i], v)
+		}
+		page.Rows = append(page.Rows, row)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// RowWriter receives the rows StreamTable reads
+type RowWriter interface {
+	Header(columns []string) error
+	Row(cells []model.Cell) error
+}
+
+// StreamTable reads every row of a relation, in the given sort order or else
+// by its key, and hands them to w as they arrive instead of holding them in
+// memory. Cells are made as for the grid, so an export of the table matches
+// one of the grid. It returns the number of rows written.
+func (db *Database) StreamTable(ctx context.Context, table model.TableItem, sortKeys []model.SortKey, keyColumns []string, w RowWriter) (int64, error) {
+	query := "SELECT * FROM " + table.Identifier()
+	var order []string
+	if len(sortKeys) > 0 {
+		order = append(order, orderByClause(sortKeys))
+	}
+	if len(keyColumns) > 0 {
+		order = append(order, quoteIdents(keyColumns))
+	}
+	if len(order) > 0 {
+		query += " ORDER BY " + strings.Join(order, ", ")
 	}
 
-	return data, columns, nil
+	rows, err := db.pool.Query(ctx, query, pgx.QueryResultFormats{pgx.TextFormatCode})
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	fieldDescriptions := rows.FieldDescriptions()
+	columns := make([]string, len(fieldDescriptions))
+	columnOIDs := make([]uint32, len(fieldDescriptions))
+	for i, fd := range fieldDescriptions {
+		columns[i] = string(fd.Name)
+		columnOIDs[i] = fd.DataTypeOID
+	}
+	if err := w.Header(columns); err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for rows.Next() {
+		values := rows.RawValues()
+		cells := make([]model.Cell, len(values))
+		for i, v := range values {
+			cells[i] = db.makeCell(columnOIDs[i], v)
+		}
+		if err := w.Row(cells); err != nil {
+			return count, err
+		}
+		count++
+	}
+	rows.Close()
+	return count, rows.Err()
+}
+
+// escapeLike escapes the LIKE wildcards in a literal pattern
+func escapeLike(pattern string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return replacer.Replace(pattern)
+}
+
+// orderByClause builds the ORDER BY list for a set of sort keys
+func orderByClause(sortKeys []model.SortKey) string {
+	terms := make([]string, len(sortKeys))
+	for i, sortKey := range sortKeys {
+		terms[i] = pgx.Identifier{sortKey.Column}.Sanitize()
+		if sortKey.Descending {
+			terms[i] += " DESC"
+		}
+	}
+	return strings.Join(terms, ", ")
+}
+
+// quoteIdents quotes and joins a list of column names
+func quoteIdents(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = pgx.Identifier{name}.Sanitize()
+	}
+	return strings.Join(quoted, ", ")
 }
@@ -0,0 +1,418 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+// objectTypes names the relation kinds as COMMENT ON and ALTER ... OWNER TO
+// spell them
+var objectTypes = map[string]string{
+	model.KindTable:            "TABLE",
+	model.KindPartitionedTable: "TABLE",
+	model.KindForeignTable:     "FOREIGN TABLE",
+	model.KindView:             "VIEW",
+	model.KindMaterializedView: "MATERIALIZED VIEW",
+}
+
+// relationDDL holds what FetchDDL reads about a relation from pg_class
+type relationDDL struct {
+	kind      string
+	owner     string
+	comment   string
+	partKey   string // PARTITION BY clause of a partitioned table
+	parent    string // Parent of a partition
+	bound     string // Partition bound of a partition
+	inherits  string // Parents of a table using plain inheritance
+	viewDef   string
+	server    string // Server of a foreign table
+	options   string // Options of a foreign table
+	partition bool
+	unlogged  bool
+	storage   string // Storage parameters, as in WITH (...)
+	ofType    string // Composite type of a typed table
+}
+
+// FetchDDL reconstructs the statements that create a relation: the CREATE
+// statement with its columns and constraints, or the view definition,
+// followed by its indexes, triggers, comments, owner and grants
+func (db *Database) FetchDDL(ctx context.Context, table model.TableItem) (string, error) {
+	var r relationDDL
+	err := db.pool.QueryRow(ctx, `
+        SELECT c.relkind::text, c.relispartition,
+               pg_catalog.pg_get_userbyid(c.relowner),
+               COALESCE(pg_catalog.obj_description(c.oid, 'pg_class'), ''),
+               CASE WHEN c.relkind = 'p' THEN pg_catalog.pg_get_partkeydef(c.oid) ELSE '' END,
+               COALESCE((SELECT i.inhparent::regclass::text FROM pg_catalog.pg_inherits i
+                         WHERE i.inhrelid = c.oid AND c.relispartition), ''),
+               COALESCE(pg_catalog.pg_get_expr(c.relpartbound, c.oid), ''),
+               COALESCE((SELECT string_agg(i.inhparent::regclass::text, ', ' ORDER BY i.inhseqno)
+                         FROM pg_catalog.pg_inherits i
+                         WHERE i.inhrelid = c.oid AND NOT c.relispartition), ''),
+               CASE WHEN c.relkind IN ('v', 'm') THEN pg_catalog.pg_get_viewdef(c.oid, true) ELSE '' END,
+               COALESCE((SELECT quote_ident(s.srvname) FROM pg_catalog.pg_foreign_table ft
+                         JOIN pg_catalog.pg_foreign_server s ON s.oid = ft.ftserver
+                         WHERE ft.ftrelid = c.oid), ''),
+               COALESCE((SELECT string_agg(quote_ident(o.option_name) || ' ' || quote_literal(o.option_value), ', ')
+                         FROM pg_catalog.pg_foreign_table ft, pg_catalog.pg_options_to_table(ft.ftoptions) o
+                         WHERE ft.ftrelid = c.oid), ''),
+               c.relpersistence = 'u',
+               COALESCE((SELECT string_agg(o.name || '=' || quote_literal(o.value), ', ')
+                         FROM (SELECT option_name AS name, option_value AS value
+                               FROM pg_catalog.pg_options_to_table(c.reloptions)
+                               UNION ALL
+                               SELECT 'toast.' || option_name, option_value
+                               FROM pg_catalog.pg_class t, pg_catalog.pg_options_to_table(t.reloptions)
+                               WHERE t.oid = c.reltoastrelid) o), ''),
+               COALESCE(NULLIF(c.reloftype, 0)::regtype::text, '')
+        FROM pg_catalog.pg_class c
+        WHERE c.oid = $1::regclass;
+    `, table.Identifier()).Scan(&r.kind, &r.partition, &r.owner, &r.comment, &r.partKey, &r.parent,
+		&r.bound, &r.inherits, &r.viewDef, &r.server, &r.options, &r.unlogged, &r.storage, &r.ofType)
+	if err != nil {
+		return "", err
+	}
+
+	columns, err := db.FetchColumnDetails(ctx, table)
+	if err != nil {
+		return "", err
+	}
+
+	var statements []string
+	switch r.kind {
+	case model.KindView:
+		statements = append(statements, fmt.Sprintf("CREATE OR REPLACE VIEW %s AS\n%s;", table.Identifier(), viewQuery(r.viewDef)))
+	case model.KindMaterializedView:
+		statements = append(statements, fmt.Sprintf("CREATE MATERIALIZED VIEW %s AS\n%s\nWITH DATA;", table.Identifier(), viewQuery(r.viewDef)))
+	default:
+		create, err := db.createTable(ctx, table, r, columns)
+		if err != nil {
+			return "", err
+		}
+		sequences, owners, err := db.ownedSequences(ctx, table)
+		if err != nil {
+			return "", err
+		}
+		statements = append(statements, strings.Join(sequences, "\n"), create, strings.Join(owners, "\n"))
+	}
+
+	indexes, err := db.queryStrings(ctx, `
+        SELECT pg_catalog.pg_get_indexdef(i.indexrelid) || ';'
+        FROM pg_catalog.pg_index i
+        JOIN pg_catalog.pg_class c ON c.oid = i.indexrelid
+        WHERE i.indrelid = $1::regclass
+          AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_constraint k
+                          WHERE k.conrelid = i.indrelid AND k.conindid = i.indexrelid)
+          AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_inherits h WHERE h.inhrelid = i.indexrelid)
+        ORDER BY c.relname;
+    `, table.Identifier())
+	if err != nil {
+		return "", err
+	}
+	statements = append(statements, strings.Join(indexes, "\n"))
+
+	triggers, err := db.queryStrings(ctx, `
+        SELECT pg_catalog.pg_get_triggerdef(t.oid, true) || ';'
+        FROM pg_catalog.pg_trigger t
+        WHERE t.tgrelid = $1::regclass AND NOT t.tgisinternal
+        ORDER BY t.tgname;
+    `, table.Identifier())
+	if err != nil {
+		return "", err
+	}
+	statements = append(statements, strings.Join(triggers, "\n"))
+
+	objectType := objectTypes[r.kind]
+	var comments []string
+	if r.comment != "" {
+		comments = append(comments, fmt.Sprintf("COMMENT ON %s %s IS %s;", objectType, table.Identifier(), quoteLiteral(&r.comment)))
+	}
+	for _, c := range columns {
+		if c.Comment != "" {
+			comments = append(comments, fmt.Sprintf("COMMENT ON COLUMN %s.%s IS %s;",
+				table.Identifier(), pgx.Identifier{c.Name}.Sanitize(), quoteLiteral(&c.Comment)))
+		}
+	}
+	statements = append(statements, strings.Join(comments, "\n"))
+
+	statements = append(statements, fmt.Sprintf("ALTER %s %s OWNER TO %s;", objectType, table.Identifier(), pgx.Identifier{r.owner}.Sanitize()))
+
+	grants, err := db.queryStrings(ctx, `
+        SELECT format('GRANT %s ON TABLE %s TO %s%s;',
+                      string_agg(a.privilege_type, ', ' ORDER BY a.privilege_type),
+                      $2::text,
+                      CASE WHEN a.grantee = 0 THEN 'PUBLIC' ELSE quote_ident(pg_catalog.pg_get_userbyid(a.grantee)) END,
+                      CASE WHEN a.is_grantable THEN ' WITH GRANT OPTION' ELSE '' END)
+        FROM pg_catalog.pg_class c, pg_catalog.aclexplode(c.relacl) a
+        WHERE c.oid = $1::regclass AND a.grantee <> c.relowner
+        GROUP BY a.grantee, a.is_grantable
+        ORDER BY a.grantee, a.is_grantable;
+    `, table.Identifier(), table.Identifier())
+	if err != nil {
+		return "", err
+	}
+	columnGrants, err := db.queryStrings(ctx, `
+        SELECT format('GRANT %s (%s) ON TABLE %s TO %s%s;',
+                      x.privilege_type,
+                      string_agg(quote_ident(a.attname), ', ' ORDER BY a.attnum),
+                      $2::text,
+                      CASE WHEN x.grantee = 0 THEN 'PUBLIC' ELSE quote_ident(pg_catalog.pg_get_userbyid(x.grantee)) END,
+                      CASE WHEN x.is_grantable THEN ' WITH GRANT OPTION' ELSE '' END)
+        FROM pg_catalog.pg_class c
+        JOIN pg_catalog.pg_attribute a ON a.attrelid = c.oid AND a.attnum > 0 AND NOT a.attisdropped,
+             pg_catalog.aclexplode(a.attacl) x
+        WHERE c.oid = $1::regclass AND x.grantee <> c.relowner
+        GROUP BY x.grantee, x.is_grantable, x.privilege_type
+        ORDER BY x.grantee, x.is_grantable, x.privilege_type;
+    `, table.Identifier(), table.Identifier())
+	if err != nil {
+		return "", err
+	}
+	statements = append(statements, strings.Join(append(grants, columnGrants...), "\n"))
+
+	return joinStatements(statements), nil
+}
+
+// createTable renders the CREATE statement of a table, partitioned table,
+// partition, typed table or foreign table. Constraints are part of the
+// CREATE TABLE, except on partitions where those of their own are added
+// afterwards.
+func (db *Database) createTable(ctx context.Context, table model.TableItem, r relationDDL, columns []model.ColumnDetail) (string, error) {
+	constraints, err := db.queryStrings(ctx, `
+        SELECT format('CONSTRAINT %I %s', conname, pg_catalog.pg_get_constraintdef(oid, true))
+        FROM pg_catalog.pg_constraint
+        WHERE conrelid = $1::regclass AND contype NOT IN ('t', 'n') AND (conislocal OR NOT $2)
+        ORDER BY array_position(ARRAY['p', 'u', 'f', 'c', 'x'], contype::text), conname;
+    `, table.Identifier(), r.partition)
+	if err != nil {
+		return "", err
+	}
+
+	keyword := "TABLE"
+	switch {
+	case r.kind == model.KindForeignTable:
+		keyword = "FOREIGN TABLE"
+	case r.unlogged:
+		keyword = "UNLOGGED TABLE"
+	}
+
+	if r.partition {
+		create := fmt.Sprintf("CREATE %s %s PARTITION OF %s\n    %s", keyword, table.Identifier(), r.parent, r.bound)
+		if r.partKey != "" {
+			create += "\n    PARTITION BY " + r.partKey
+		}
+		if r.storage != "" {
+			create += "\n    WITH (" + r.storage + ")"
+		}
+		statements := []string{create + ";"}
+		for _, c := range constraints {
+			statements = append(statements, fmt.Sprintf("ALTER TABLE %s ADD %s;", table.Identifier(), c))
+		}
+		return strings.Join(statements, "\n"), nil
+	}
+
+	lines := make([]string, 0, len(columns)+len(constraints))
+	for _, c := range columns {
+		switch {
+		case r.ofType == "":
+			lines = append(lines, "    "+columnDefinition(c))
+		case columnOptions(c) != "":
+			// A typed table takes its columns from the type; only their
+			// options are its own
+			lines = append(lines, "    "+pgx.Identifier{c.Name}.Sanitize()+" WITH OPTIONS "+columnOptions(c))
+		}
+	}
+	for _, c := range constraints {
+		lines = append(lines, "    "+c)
+	}
+
+	create := fmt.Sprintf("CREATE %s %s", keyword, table.Identifier())
+	if r.ofType != "" {
+		create += " OF " + r.ofType
+	}
+	if len(lines) > 0 || r.ofType == "" {
+		create += " (\n" + strings.Join(lines, ",\n") + "\n)"
+	}
+	if r.inherits != "" {
+		create += "\nINHERITS (" + r.inherits + ")"
+	}
+	if r.partKey != "" {
+		create += "\nPARTITION BY " + r.partKey
+	}
+	if r.storage != "" {
+		create += "\nWITH (" + r.storage + ")"
+	}
+	if r.server != "" {
+		create += "\nSERVER " + r.server
+		if r.options != "" {
+			create += "\nOPTIONS (" + r.options + ")"
+		}
+	}
+	return create + ";", nil
+}
+
+// columnDefinition renders a column as it appears in CREATE TABLE
+func columnDefinition(c model.ColumnDetail) string {
+	parts := []string{pgx.Identifier{c.Name}.Sanitize(), c.Type}
+	if c.Collation != "" {
+		parts = append(parts, "COLLATE "+pgx.Identifier{c.Collation}.Sanitize())
+	}
+	if options := columnOptions(c); options != "" {
+		parts = append(parts, options)
+	}
+	return strings.Join(parts, " ")
+}
+
+// columnOptions renders the default, generation and NOT NULL of a column
+func columnOptions(c model.ColumnDetail) string {
+	var parts []string
+	switch {
+	case c.Generated != "":
+		parts = append(parts, "GENERATED ALWAYS AS ("+c.Generated+") STORED")
+	case c.Identity != "":
+		parts = append(parts, "GENERATED "+c.Identity+" AS IDENTITY")
+	case c.Default != "":
+		parts = append(parts, "DEFAULT "+c.Default)
+	}
+	if c.NotNull {
+		parts = append(parts, "NOT NULL")
+	}
+	return strings.Join(parts, " ")
+}
+
+// ownedSequences renders the sequences owned by columns of a table, as those
+// of serial columns are: the CREATE SEQUENCE statements to run before the
+// table is created, and the ALTER SEQUENCE ... OWNED BY to run after.
+// Identity columns create their sequences themselves.
+func (db *Database) ownedSequences(ctx context.Context, table model.TableItem) ([]string, []string, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT format('CREATE SEQUENCE %s AS %s INCREMENT BY %s MINVALUE %s MAXVALUE %s START WITH %s CACHE %s%s;',
+                      s.seqrelid::regclass, pg_catalog.format_type(s.seqtypid, NULL), s.seqincrement,
+                      s.seqmin, s.seqmax, s.seqstart, s.seqcache,
+                      CASE WHEN s.seqcycle THEN ' CYCLE' ELSE '' END),
+               format('ALTER SEQUENCE %s OWNED BY %s.%I;', s.seqrelid::regclass, $2::text, a.attname)
+        FROM pg_catalog.pg_depend d
+        JOIN pg_catalog.pg_sequence s ON s.seqrelid = d.objid
+        JOIN pg_catalog.pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+        WHERE d.classid = 'pg_catalog.pg_class'::regclass
+          AND d.refclassid = 'pg_catalog.pg_class'::regclass
+          AND d.refobjid = $1::regclass
+          AND d.deptype = 'a'
+        ORDER BY a.attnum;
+    `, table.Identifier(), table.Identifier())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	var creates, owners []string
+	for rows.Next() {
+		var create, owner string
+		if err := rows.Scan(&create, &owner); err != nil {
+			return nil, nil, err
+		}
+		creates = append(creates, create)
+		owners = append(owners, owner)
+	}
+	return creates, owners, rows.Err()
+}
+
+// FetchFunctions lists the functions and procedures outside the system
+// schemas that pg_get_functiondef can render, leaving out those belonging
+// to extensions
+func (db *Database) FetchFunctions(ctx context.Context) ([]model.FunctionItem, error) {
+	rows, err := db.pool.Query(ctx, `
+        SELECT n.nspname, p.proname, p.oid::regprocedure::text,
+               pg_catalog.pg_get_function_identity_arguments(p.oid), p.prokind = 'p'
+        FROM pg_catalog.pg_proc p
+        JOIN pg_catalog.pg_namespace n ON n.oid = p.pronamespace
+        WHERE p.prokind IN ('f', 'p')
+          AND n.nspname <> 'information_schema'
+          AND n.nspname !~ '^pg_'
+          AND NOT EXISTS (SELECT 1 FROM pg_catalog.pg_depend d
+                          WHERE d.classid = 'pg_catalog.pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e')
+        ORDER BY n.nspname, p.proname, 4;
+    `)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var functions []model.FunctionItem
+	for rows.Next() {
+		var f model.FunctionItem
+		if err := rows.Scan(&f.Schema, &f.Name, &f.Signature, &f.Arguments, &f.Procedure); err != nil {
+			return nil, err
+		}
+		functions = append(functions, f)
+	}
+	return functions, rows.Err()
+}
+
+// FetchFunctionDDL returns the CREATE statement of a function, given its
+// signature as regprocedure renders it, with its comment and owner
+func (db *Database) FetchFunctionDDL(ctx context.Context, signature string) (string, error) {
+	var definition, owner, comment string
+	err := db.pool.QueryRow(ctx, `
+        SELECT pg_catalog.pg_get_functiondef(p.oid),
+               pg_catalog.pg_get_userbyid(p.proowner),
+               COALESCE(pg_catalog.obj_description(p.oid, 'pg_proc'), '')
+        FROM pg_catalog.pg_proc p
+        WHERE p.oid = $1::regprocedure;
+    `, signature).Scan(&definition, &owner, &comment)
+	if err != nil {
+		return "", err
+	}
+
+	kind := "FUNCTION"
+	if strings.HasPrefix(definition, "CREATE OR REPLACE PROCEDURE") {
+		kind = "PROCEDURE"
+	}
+	statements := []string{strings.TrimSpace(definition) + ";"}
+	if comment != "" {
+		statements = append(statements, fmt.Sprintf("COMMENT ON %s %s IS %s;", kind, signature, quoteLiteral(&comment)))
+	}
+	statements = append(statements, fmt.Sprintf("ALTER %s %s OWNER TO %s;", kind, signature, pgx.Identifier{owner}.Sanitize()))
+	return joinStatements(statements), nil
+}
+
+// queryStrings runs a query returning one text column and collects it
+func (db *Database) queryStrings(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := db.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
+
+// viewQuery trims the terminating semicolon pg_get_viewdef ends with
+func viewQuery(definition string) string {
+	return strings.TrimSuffix(strings.TrimRight(definition, " \n"), ";")
+}
+
+// joinStatements separates groups of statements by a blank line, leaving out
+// empty ones
+func joinStatements(statements []string) string {
+	var parts []string
+	for _, s := range statements {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, "\n\n") + "\n"
+}
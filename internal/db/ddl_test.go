@@ -0,0 +1,35 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+func TestColumnDefinition(t *testing.T) {
+	tests := []struct {
+		name    string
+		column  model.ColumnDetail
+		want    string
+		options string
+	}{
+		{"plain", model.ColumnDetail{Name: "note", Type: "text"}, `"note" text`, ""},
+		{"serial", model.ColumnDetail{Name: "id", Type: "integer", Default: "nextval('t_id_seq'::regclass)", NotNull: true},
+			`"id" integer DEFAULT nextval('t_id_seq'::regclass) NOT NULL`, "DEFAULT nextval('t_id_seq'::regclass) NOT NULL"},
+		{"identity", model.ColumnDetail{Name: "id", Type: "bigint", Identity: "ALWAYS", NotNull: true},
+			`"id" bigint GENERATED ALWAYS AS IDENTITY NOT NULL`, "GENERATED ALWAYS AS IDENTITY NOT NULL"},
+		{"generated", model.ColumnDetail{Name: "total", Type: "numeric", Generated: "price * qty", Default: "ignored"},
+			`"total" numeric GENERATED ALWAYS AS (price * qty) STORED`, "GENERATED ALWAYS AS (price * qty) STORED"},
+		{"collation", model.ColumnDetail{Name: "Name", Type: "text", Collation: "C"}, `"Name" text COLLATE "C"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := columnDefinition(tt.column); got != tt.want {
+				t.Errorf("columnDefinition() = %s, want %s", got, tt.want)
+			}
+			if got := columnOptions(tt.column); got != tt.options {
+				t.Errorf("columnOptions() = %s, want %s", got, tt.options)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// RowKey identifies one row of a relation by its key column values, in the
+// server's text form
+type RowKey struct {
+	Columns []string
+	Values  []string
+}
+
+// String joins the key values into an identifier for the row
+func (k RowKey) String() string {
+	return strings.Join(k.Values, "\x00")
+}
+
+// whereClause builds the condition matching a row key, numbering its
+// parameters after the args already in use
+func (k RowKey) whereClause(args []any) (string, []any) {
+	placeholders := make([]string, len(k.Values))
+	for i, value := range k.Values {
+		args = append(args, value)
+		placeholders[i] = fmt.Sprintf("$%d", len(args))
+	}
+	return fmt.Sprintf("(%s) = (%s)", quoteIdents(k.Columns), strings.Join(placeholders, ", ")), args
+}
+
+// UpdateCell sets one column of the row with the given key and returns the
+// stored value (as the server now renders it) along with the number of rows
+// updated. A nil value sets the column to NULL.
+func (db *Database) UpdateCell(ctx context.Context, table model.TableItem, key RowKey, column string, value *string) (model.Cell, int64, error) {
+	if db.readOnly {
+		return model.Cell{}, 0, ErrReadOnly
+	}
+	if len(key.Columns) == 0 {
+		return model.Cell{}, 0, fmt.Errorf("%s has no primary key or unique key to identify rows by", table.QualifiedName())
+	}
+
+	// String parameters are sent as text, so the server converts them to the
+	// column's type just as it would a literal
+	var arg any
+	if value != nil {
+		arg = *value
+	}
+	where, args := key.whereClause([]any{arg})
+	ident := pgx.Identifier{column}.Sanitize()
+	query := fmt.Sprintf("UPDATE %s SET %s = $1 WHERE %s RETURNING %s", table.Identifier(), ident, where, ident)
+
+	rows, err := db.pool.Query(ctx, query, append([]any{pgx.QueryResultFormats{pgx.TextFormatCode}}, args...)...)
+	if err != nil {
+		return model.Cell{}, 0, err
+	}
+	return db.readUpdatedCell(rows)
+}
+
+// readUpdatedCell reads the value UpdateCell's RETURNING clause gives back.
+// The field descriptions are only read for a returned row: when the server
+// rejects the statement (a value the column type can't take, or a cancel
+// before it ran) Query doesn't fail, there are none and rows.Err says why.
+func (db *Database) readUpdatedCell(rows pgx.Rows) (model.Cell, int64, error) {
+	defer rows.Close()
+
+	var cell model.Cell
+	for rows.Next() {
+		cell = db.makeCell(rows.FieldDescriptions()[0].DataTypeOID, rows.RawValues()[0])
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.Cell{}, 0, err
+	}
+	return cell, rows.CommandTag().RowsAffected(), nil
+}
+
+// InsertRow inserts a row with the given column values; the other columns
+// take their defaults. A nil value inserts NULL.
+func (db *Database) InsertRow(ctx context.Context, table model.TableItem, columns []string, values []*string) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	query := "INSERT INTO " + table.Identifier() + " DEFAULT VALUES"
+	args := make([]any, len(values))
+	if len(columns) > 0 {
+		placeholders := make([]string, len(values))
+		for i, value := range values {
+			if value != nil {
+				args[i] = *value
+			}
+			placeholders[i] = fmt.Sprintf("$%d", i+1)
+		}
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table.Identifier(), quoteIdents(columns), strings.Join(placeholders, ", "))
+	}
+
+	tag, err := db.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// DeleteRows deletes the rows with the given keys in one statement and
+// returns the number of rows deleted
+func (db *Database) DeleteRows(ctx context.Context, table model.TableItem, keys []RowKey) (int64, error) {
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if len(keys[0].Columns) == 0 {
+		return 0, fmt.Errorf("%s has no primary key or unique key to identify rows by", table.QualifiedName())
+	}
+
+	var args []any
+	conditions := make([]string, len(keys))
+	for i, key := range keys {
+		conditions[i], args = key.whereClause(args)
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", table.Identifier(), strings.Join(conditions, " OR "))
+
+	tag, err := db.pool.Exec(ctx, query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
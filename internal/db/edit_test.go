@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// fakeRows is a result set of text values with a single column of the
+// given type, or one that failed with err before describing any fields
+type fakeRows struct {
+	oid    uint32
+	values [][]byte
+	tag    pgconn.CommandTag
+	err    error
+	next   int
+}
+
+func (r *fakeRows) Close()                        {}
+func (r *fakeRows) Err() error                    { return r.err }
+func (r *fakeRows) CommandTag() pgconn.CommandTag { return r.tag }
+func (r *fakeRows) Scan(...any) error             { return errors.New("not supported") }
+func (r *fakeRows) Values() ([]any, error)        { return nil, errors.New("not supported") }
+func (r *fakeRows) Conn() *pgx.Conn               { return nil }
+
+func (r *fakeRows) FieldDescriptions() []pgconn.FieldDescription {
+	if r.err != nil {
+		return nil
+	}
+	return []pgconn.FieldDescription{{Name: "value", DataTypeOID: r.oid}}
+}
+
+func (r *fakeRows) Next() bool {
+	if r.err != nil || r.next == len(r.values) {
+		return false
+	}
+	r.next++
+	return true
+}
+
+func (r *fakeRows) RawValues() [][]byte {
+	return [][]byte{r.values[r.next-1]}
+}
+
+func TestReadUpdatedCell(t *testing.T) {
+	db := &Database{}
+
+	t.Run("updated", func(t *testing.T) {
+		rows := &fakeRows{oid: 25, values: [][]byte{[]byte("new")}, tag: pgconn.NewCommandTag("UPDATE 1")}
+		cell, affected, err := db.readUpdatedCell(rows)
+		if err != nil || affected != 1 || cell.Text() != "new" {
+			t.Errorf("readUpdatedCell() = %q, %d, %v, want \"new\", 1, nil", cell.Text(), affected, err)
+		}
+	})
+
+	t.Run("set to NULL", func(t *testing.T) {
+		rows := &fakeRows{oid: 25, values: [][]byte{nil}, tag: pgconn.NewCommandTag("UPDATE 1")}
+		cell, _, err := db.readUpdatedCell(rows)
+		if err != nil || !cell.Null {
+			t.Errorf("readUpdatedCell() = %+v, %v, want a NULL cell", cell, err)
+		}
+	})
+
+	t.Run("no row matched", func(t *testing.T) {
+		rows := &fakeRows{oid: 25, tag: pgconn.NewCommandTag("UPDATE 0")}
+		if _, affected, err := db.readUpdatedCell(rows); err != nil || affected != 0 {
+			t.Errorf("readUpdatedCell() = %d, %v, want 0, nil", affected, err)
+		}
+	})
+
+	t.Run("rejected at bind", func(t *testing.T) {
+		// What Query gives back for 'abc' sent to an integer column
+		bindErr := &pgconn.PgError{Code: "22P02", Message: `invalid input syntax for type integer: "abc"`}
+		_, _, err := db.readUpdatedCell(&fakeRows{err: bindErr})
+		if !errors.Is(err, bindErr) {
+			t.Errorf("readUpdatedCell() error = %v, want %v", err, bindErr)
+		}
+	})
+}
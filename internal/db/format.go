@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+// Formatter renders the text representation PostgreSQL sends for a value of
+// one type as the string shown in the UI
+type Formatter func(raw []byte) string
+
+var (
+	formattersMu   sync.RWMutex
+	oidFormatters  = map[uint32]Formatter{}
+	typeFormatters = map[string]Formatter{}
+)
+
+// RegisterFormatter registers a formatter for the type with the given OID.
+// Use it for built-in types, whose OIDs are the same in every database.
+func RegisterFormatter(oid uint32, f Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	oidFormatters[oid] = f
+}
+
+// RegisterTypeFormatter registers a formatter for a type by name. Extension
+// types such as PostGIS geometry or hstore get a different OID in every
+// database, so these are resolved to OIDs when a connection is made.
+func RegisterTypeFormatter(typeName string, f Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	typeFormatters[typeName] = f
+}
+
+// loadFormatters builds the OID-keyed formatter table for this connection,
+// resolving the named extension type formatters against pg_type
+func (db *Database) loadFormatters(ctx context.Context) error {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+
+	db.formatters = make(map[uint32]Formatter, len(oidFormatters)+len(typeFormatters))
+	for oid, f := range oidFormatters {
+		db.formatters[oid] = f
+	}
+	if len(typeFormatters) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(typeFormatters))
+	for name := range typeFormatters {
+		names = append(names, name)
+	}
+	rows, err := db.pool.Query(ctx, `
+        SELECT oid, typname FROM pg_catalog.pg_type WHERE typname = ANY($1);
+    `, names)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var oid uint32
+		var name string
+		if err := rows.Scan(&oid, &name); err != nil {
+			return err
+		}
+		db.formatters[oid] = typeFormatters[name]
+	}
+	return rows.Err()
+}
+
+// FormatValue renders a text-format value of the given type. Types without a
+// registered formatter are shown in PostgreSQL's canonical text form, exactly
+// as the server sends it, so numerics keep their full precision and jsonb,
+// bytea, timestamps, intervals, arrays, ranges, inet and enums read the same
+// as they would in psql.
+func (db *Database) FormatValue(oid uint32, raw []byte) string {
+	if f, ok := db.formatters[oid]; ok {
+		return f(raw)
+	}
+	return string(raw)
+}
+
+// makeCell builds the cell for a text-format value, keeping the server's
+// text when a formatter changes it so the value can be sent back as is
+func (db *Database) makeCell(oid uint32, raw []byte) model.Cell {
+	if raw == nil {
+		return model.NullCell()
+	}
+	cell := model.Cell{Value: db.FormatValue(oid, raw)}
+	if text := string(raw); cell.Value != text {
+		cell.Raw = &text
+	}
+	return cell
+}
@@ -0,0 +1,34 @@
+package db
+
+import "testing"
+
+func TestMakeCell(t *testing.T) {
+	const oid = 1
+	db := &Database{formatters: map[uint32]Formatter{
+		oid: func(raw []byte) string {
+			if len(raw) == 0 {
+				return "(empty)"
+			}
+			return string(raw)
+		},
+	}}
+	tests := []struct {
+		name  string
+		raw   []byte
+		value string
+		text  string
+		null  bool
+	}{
+		{"NULL", nil, "", "", true},
+		{"kept as is", []byte("abc"), "abc", "abc", false},
+		{"empty string reformatted", []byte{}, "(empty)", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cell := db.makeCell(oid, tt.raw)
+			if cell.Null != tt.null || cell.Value != tt.value || cell.Text() != tt.text {
+				t.Errorf("makeCell() = %+v with Text() %q, want value %q, text %q, null %v", cell, cell.Text(), tt.value, tt.text, tt.null)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseParams finds the parameters of a statement. Positional $n parameters
+// are kept as they are; :name parameters are replaced by $n placeholders
+// numbered after the positional ones, so the statement can be bound by pgx.
+// It returns the rewritten statement and a name for every placeholder, in
+// placeholder order ("$1" for positional ones, the bare name for named ones).
+// String literals, quoted identifiers, comments, :: casts and array slices
+// such as arr[1:n] are skipped.
+func ParseParams(sql string) (string, []string) {
+	positional := 0
+	var named []string
+	var out strings.Builder
+
+	// First pass: find the highest positional parameter
+	scanSQL(sql, func(token string, kind tokenKind) {
+		if kind == tokenPositional {
+			if n, err := strconv.Atoi(token[1:]); err == nil && n > positional {
+				positional = n
+			}
+		}
+	})
+
+	// Second pass: number the named parameters after the positional ones
+	scanSQL(sql, func(token string, kind tokenKind) {
+		if kind != tokenNamed {
+			out.WriteString(token)
+			return
+		}
+		name := token[1:]
+		index := -1
+		for i, n := range named {
+			if n == name {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			named = append(named, name)
+			index = len(named) - 1
+		}
+		fmt.Fprintf(&out, "$%d", positional+index+1)
+	})
+
+	names := make([]string, 0, positional+len(named))
+	for i := 1; i <= positional; i++ {
+		names = append(names, fmt.Sprintf("$%d", i))
+	}
+	return out.String(), append(names, named...)
+}
+
+// tokenKind classifies the pieces scanSQL splits a statement into
+type tokenKind int
+
+const (
+	tokenText       tokenKind = iota // Anything that is not a parameter
+	tokenPositional                  // $1
+	tokenNamed                       // :name
+)
+
+// scanSQL splits a statement into parameters and the text between them
+func scanSQL(sql string, emit func(token string, kind tokenKind)) {
+	start := 0
+	var subscripts []bool // Open brackets, true for subscripts rather than ARRAY[...]
+	flush := func(end int) {
+		if end > start {
+			emit(sql[start:end], tokenText)
+		}
+		start = end
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == '\'':
+			// String literal; E'...' strings also escape with backslashes
+			escapes := i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e')
+			i = skipQuoted(sql, i, '\'', escapes)
+		case c == '"':
+			i = skipQuoted(sql, i, '"', false)
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(sql)
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			i = skipBlockComment(sql, i)
+		case c == ':' && strings.HasPrefix(sql[i:], "::"):
+			i += 2
+		case c == '[':
+			subscripts = append(subscripts, isSubscript(sql, i))
+			i++
+		case c == ']':
+			if len(subscripts) > 0 {
+				subscripts = subscripts[:len(subscripts)-1]
+			}
+			i++
+		case c == ':' && i+1 < len(sql) && isIdentStart(sql[i+1]) &&
+			!(len(subscripts) > 0 && subscripts[len(subscripts)-1]) &&
+			(i == 0 || (!isDigit(sql[i-1]) && sql[i-1] != ']')):
+			end := i + 2
+			for end < len(sql) && isIdentChar(sql[end]) {
+				end++
+			}
+			flush(i)
+			emit(sql[i:end], tokenNamed)
+			start, i = end, end
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]) && (i == 0 || !isIdentChar(sql[i-1])):
+			end := i + 1
+			for end < len(sql) && isDigit(sql[end]) {
+				end++
+			}
+			flush(i)
+			emit(sql[i:end], tokenPositional)
+			start, i = end, end
+		case c == '$':
+			i = skipDollarQuoted(sql, i)
+		default:
+			i++
+		}
+	}
+	flush(len(sql))
+}
+
+// skipQuoted returns the index after the quoted text starting at i. A doubled
+// quote stands for the quote itself.
+func skipQuoted(sql string, i int, quote byte, backslashEscapes bool) int {
+	for i++; i < len(sql); i++ {
+		switch {
+		case backslashEscapes && sql[i] == '\\':
+			i++
+		case sql[i] == quote:
+			if i+1 < len(sql) && sql[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// skipBlockComment returns the index after the (possibly nested) /* comment */
+// starting at i
+func skipBlockComment(sql string, i int) int {
+	depth := 0
+	for i < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[i:], "/*"):
+			depth++
+			i += 2
+		case strings.HasPrefix(sql[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// skipDollarQuoted returns the index after the $tag$...$tag$ string starting
+// at i, or just past the $ when it does not start one
+func skipDollarQuoted(sql string, i int) int {
+	end := i + 1
+	for end < len(sql) && isIdentChar(sql[end]) {
+		end++
+	}
+	if end >= len(sql) || sql[end] != '$' {
+		return i + 1
+	}
+	tag := sql[i : end+1]
+	if close := strings.Index(sql[end+1:], tag); close >= 0 {
+		return end + 1 + close + len(tag)
+	}
+	return len(sql)
+}
+
+// isSubscript reports whether the bracket at i subscripts or slices the
+// expression before it, rather than opening an ARRAY[...] constructor or one
+// of its dimensions, whose elements may be parameters
+func isSubscript(sql string, i int) bool {
+	before := strings.TrimRight(sql[:i], " \t\r\n")
+	if before == "" {
+		return false
+	}
+	if last := before[len(before)-1]; !isIdentChar(last) && last != ']' && last != ')' && last != '"' {
+		return false
+	}
+	n := len(before)
+	return n < 5 || !strings.EqualFold(before[n-5:], "array") || (n > 5 && isIdentChar(before[n-6]))
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || isDigit(c)
+}
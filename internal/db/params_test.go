@@ -0,0 +1,47 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		sql   string
+		want  string
+		names []string
+	}{
+		{"none", "SELECT 1", "SELECT 1", []string{}},
+		{"named", "SELECT * FROM t WHERE id = :id", "SELECT * FROM t WHERE id = $1", []string{"id"}},
+		{"repeated name", "SELECT :a, :b, :a", "SELECT $1, $2, $1", []string{"a", "b"}},
+		{"after positional", "SELECT $2, :x, $1", "SELECT $2, $3, $1", []string{"$1", "$2", "x"}},
+		{"string literal", "SELECT ':a', :b", "SELECT ':a', $1", []string{"b"}},
+		{"doubled quote", "SELECT 'it''s :a', :b", "SELECT 'it''s :a', $1", []string{"b"}},
+		{"escape string", `SELECT E'\' :a', :b`, `SELECT E'\' :a', $1`, []string{"b"}},
+		{"quoted identifier", `SELECT ":a" FROM t WHERE x = :b`, `SELECT ":a" FROM t WHERE x = $1`, []string{"b"}},
+		{"line comment", "SELECT 1 -- :a\nWHERE x = :b", "SELECT 1 -- :a\nWHERE x = $1", []string{"b"}},
+		{"block comment", "SELECT /* :a /* :b */ :c */ :d", "SELECT /* :a /* :b */ :c */ $1", []string{"d"}},
+		{"dollar quoted", "SELECT $$ :a $$, $f$ :b $f$, :c", "SELECT $$ :a $$, $f$ :b $f$, $1", []string{"c"}},
+		{"cast", "SELECT :a::int, x::text", "SELECT $1::int, x::text", []string{"a"}},
+		{"slice with numbers", "SELECT arr[1:n] FROM t", "SELECT arr[1:n] FROM t", []string{}},
+		{"slice with names", "SELECT arr[lo:hi], arr[:hi] FROM t", "SELECT arr[lo:hi], arr[:hi] FROM t", []string{}},
+		{"slice of a slice", "SELECT (arr[1:2])[1:n], m[1][2:k]", "SELECT (arr[1:2])[1:n], m[1][2:k]", []string{}},
+		{"parameter after a slice", "SELECT arr[1:n] FROM t WHERE id = :id", "SELECT arr[1:n] FROM t WHERE id = $1", []string{"id"}},
+		{"array constructor", "SELECT ARRAY[:a, :b], array [[:c]]", "SELECT ARRAY[$1, $2], array [[$3]]", []string{"a", "b", "c"}},
+		{"identifier ending in array", "SELECT myarray[1:n]", "SELECT myarray[1:n]", []string{}},
+		{"positional in identifier", "SELECT a$1 FROM t", "SELECT a$1 FROM t", []string{}},
+		{"unterminated literal", "SELECT ':a", "SELECT ':a", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, names := ParseParams(tt.sql)
+			if got != tt.want {
+				t.Errorf("ParseParams(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+			if !reflect.DeepEqual(names, tt.names) {
+				t.Errorf("ParseParams(%q) names = %q, want %q", tt.sql, names, tt.names)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+// MaxQueryRows is the number of rows kept from an ad-hoc query's result; the
+// rest are counted by the server but not loaded
+const MaxQueryRows = 10000
+
+// ExecuteQuery runs a single SQL statement typed by the user and returns its
+// result set, or the command tag for statements that return no rows. The
+// args are bound to the statement's $n parameters.
+//
+// On a read-only connection the statement runs in a read-only transaction
+// that is rolled back afterwards, so it can neither write nor turn the
+// session's read-only default off.
+func (db *Database) ExecuteQuery(ctx context.Context, sql string, args ...any) (*model.QueryResult, error) {
+	start := time.Now()
+
+	var q interface {
+		Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	} = db.pool
+	if db.readOnly {
+		tx, err := db.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback(context.Background())
+		// Once a query has run, the transaction can't be made read-write
+		if _, err := tx.Exec(ctx, "SELECT 1"); err != nil {
+			return nil, err
+		}
+		q = tx
+	}
+
+	// Request every column in text format so values can be shown losslessly
+	args = append([]any{pgx.QueryResultFormats{pgx.TextFormatCode}}, args...)
+	rows, err := q.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fieldDescriptions := rows.FieldDescriptions()
+	result := &model.QueryResult{SQL: sql, Columns: make([]string, len(fieldDescriptions))}
+	columnOIDs := make([]uint32, len(fieldDescriptions))
+	for i, fd := range fieldDescriptions {
+		result.Columns[i] = string(fd.Name)
+		columnOIDs[i] = fd.DataTypeOID
+	}
+
+	for rows.Next() {
+		if len(result.Rows) == MaxQueryRows {
+			result.Truncated = true
+			continue
+		}
+		values := rows.RawValues()
+		row := make([]model.Cell, len(values))
+		for i, v := range values {
+			row[i] = db.makeCell(columnOIDs[i], v)
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	tag := rows.CommandTag()
+	result.CommandTag = tag.String()
+	result.RowsAffected = tag.RowsAffected()
+	result.Duration = time.Since(start)
+	return result, nil
+}
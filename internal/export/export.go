@@ -0,0 +1,315 @@
+// Package export writes rows to files in the formats data is commonly
+// exchanged in
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/utils"
+)
+
+// Format is a file format rows can be exported to
+type Format string
+
+const (
+	CSV      Format = "csv"
+	TSV      Format = "tsv"
+	JSON     Format = "json"
+	NDJSON   Format = "ndjson"
+	Markdown Format = "md"
+	HTML     Format = "html"
+)
+
+// extensions maps file extensions to the format they stand for
+var extensions = map[string]Format{
+	".csv":      CSV,
+	".tsv":      TSV,
+	".json":     JSON,
+	".ndjson":   NDJSON,
+	".jsonl":    NDJSON,
+	".md":       Markdown,
+	".markdown": Markdown,
+	".html":     HTML,
+	".htm":      HTML,
+}
+
+// Extensions lists the file extensions FormatFor understands, for hints
+const Extensions = ".csv, .tsv, .json, .ndjson, .md or .html"
+
+// FormatFor picks the format for a file from its extension
+func FormatFor(path string) (Format, error) {
+	format, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return "", fmt.Errorf("unknown file type %q, use %s", filepath.Ext(path), Extensions)
+	}
+	return format, nil
+}
+
+// Writer writes rows in one format. Header must be called first and Close
+// last; Close flushes the output but doesn't close the underlying writer.
+type Writer interface {
+	Header(columns []string) error
+	Row(cells []model.Cell) error
+	Close() error
+}
+
+// NewWriter returns a writer for a format
+func NewWriter(w io.Writer, format Format, title string) Writer {
+	out := bufio.NewWriter(w)
+	switch format {
+	case TSV:
+		return &tsvWriter{out: out}
+	case JSON:
+		return &jsonWriter{out: out, array: true}
+	case NDJSON:
+		return &jsonWriter{out: out}
+	case Markdown:
+		return &markdownWriter{out: out}
+	case HTML:
+		return &htmlWriter{out: out, title: title}
+	default:
+		return &csvWriter{out: out}
+	}
+}
+
+// csvWriter writes CSV the way COPY ... CSV HEADER does: NULL is an empty
+// unquoted field, an empty string is a quoted one
+type csvWriter struct {
+	out *bufio.Writer
+}
+
+func (w *csvWriter) Header(columns []string) error {
+	return w.line(columns, make([]bool, len(columns)))
+}
+
+func (w *csvWriter) Row(cells []model.Cell) error {
+	values := make([]string, len(cells))
+	nulls := make([]bool, len(cells))
+	for i, cell := range cells {
+		values[i], nulls[i] = cell.Text(), cell.Null
+	}
+	return w.line(values, nulls)
+}
+
+func (w *csvWriter) line(values []string, nulls []bool) error {
+	for i, value := range values {
+		if i > 0 {
+			w.out.WriteByte(',')
+		}
+		if nulls[i] {
+			continue
+		}
+		if value == "" || strings.ContainsAny(value, ",\"\r\n") || value == `\.` {
+			value = `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+		}
+		w.out.WriteString(value)
+	}
+	_, err := w.out.WriteString("\n")
+	return err
+}
+
+func (w *csvWriter) Close() error {
+	return w.out.Flush()
+}
+
+// tsvEscaper escapes values as COPY's text format does
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// tsvWriter writes tab-separated values in COPY's text format, with NULL as
+// \N
+type tsvWriter struct {
+	out *bufio.Writer
+}
+
+func (w *tsvWriter) Header(columns []string) error {
+	cells := make([]model.Cell, len(columns))
+	for i, column := range columns {
+		cells[i] = model.Cell{Value: column}
+	}
+	return w.Row(cells)
+}
+
+func (w *tsvWriter) Row(cells []model.Cell) error {
+	for i, cell := range cells {
+		if i > 0 {
+			w.out.WriteByte('\t')
+		}
+		if cell.Null {
+			w.out.WriteString(`\N`)
+		} else {
+			w.out.WriteString(tsvEscaper.Replace(cell.Text()))
+		}
+	}
+	_, err := w.out.WriteString("\n")
+	return err
+}
+
+func (w *tsvWriter) Close() error {
+	return w.out.Flush()
+}
+
+// jsonWriter writes each row as an object keyed by column, in column order,
+// either as elements of an array or one per line. Values are strings in
+// PostgreSQL's text form so none lose precision; NULL is null.
+type jsonWriter struct {
+	out     *bufio.Writer
+	array   bool
+	columns [][]byte // Encoded column names
+	rows    int
+}
+
+func (w *jsonWriter) Header(columns []string) error {
+	// Repeated names would make keys that parsers keep only one of
+	columns = utils.UniqueNames(columns)
+	w.columns = make([][]byte, len(columns))
+	for i, column := range columns {
+		w.columns[i], _ = json.Marshal(column)
+	}
+	if w.array {
+		w.out.WriteByte('[')
+	}
+	return nil
+}
+
+func (w *jsonWriter) Row(cells []model.Cell) error {
+	if w.array {
+		if w.rows > 0 {
+			w.out.WriteByte(',')
+		}
+		w.out.WriteString("\n  ")
+	}
+	w.rows++
+
+	w.out.WriteByte('{')
+	for i, cell := range cells {
+		if i > 0 {
+			w.out.WriteString(", ")
+		}
+		w.out.Write(w.columns[i])
+		w.out.WriteString(": ")
+		if cell.Null {
+			w.out.WriteString("null")
+			continue
+		}
+		value, err := json.Marshal(cell.Text())
+		if err != nil {
+			return err
+		}
+		w.out.Write(value)
+	}
+	w.out.WriteByte('}')
+	if !w.array {
+		w.out.WriteByte('\n')
+	}
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	if w.array {
+		if w.rows > 0 {
+			w.out.WriteByte('\n')
+		}
+		w.out.WriteString("]\n")
+	}
+	return w.out.Flush()
+}
+
+// markdownEscaper escapes the characters that would end a table cell or be
+// taken for markup, so no value reads like the NULL marker
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, `|`, `\|`, `*`, `\*`, `_`, `\_`, "`", "\\`", `<`, `&lt;`, `&`, `&amp;`,
+	"\r\n", "<br>", "\n", "<br>", "\r", "<br>")
+
+// markdownWriter writes a GitHub-flavored Markdown table, with NULL in
+// italics
+type markdownWriter struct {
+	out *bufio.Writer
+}
+
+func (w *markdownWriter) Header(columns []string) error {
+	cells := make([]model.Cell, len(columns))
+	separators := make([]string, len(columns))
+	for i, column := range columns {
+		cells[i] = model.Cell{Value: column}
+		separators[i] = "---"
+	}
+	if err := w.Row(cells); err != nil {
+		return err
+	}
+	_, err := w.out.WriteString("| " + strings.Join(separators, " | ") + " |\n")
+	return err
+}
+
+func (w *markdownWriter) Row(cells []model.Cell) error {
+	w.out.WriteString("|")
+	for _, cell := range cells {
+		if cell.Null {
+			w.out.WriteString(" _NULL_ |")
+		} else {
+			w.out.WriteString(" " + markdownEscaper.Replace(cell.Text()) + " |")
+		}
+	}
+	_, err := w.out.WriteString("\n")
+	return err
+}
+
+func (w *markdownWriter) Close() error {
+	return w.out.Flush()
+}
+
+// htmlWriter writes a standalone HTML page holding a table. NULL cells have
+// the null class.
+type htmlWriter struct {
+	out   *bufio.Writer
+	title string
+}
+
+func (w *htmlWriter) Header(columns []string) error {
+	title := html.EscapeString(w.title)
+	fmt.Fprintf(w.out, `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>%s</title>
+<style>
+table { border-collapse: collapse; font-family: sans-serif; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; white-space: pre-wrap; }
+td.null { color: #999; font-style: italic; }
+</style>
+</head>
+<body>
+<table>
+<caption>%s</caption>
+<thead>
+<tr>`, title, title)
+	for _, column := range columns {
+		w.out.WriteString("<th>" + html.EscapeString(column) + "</th>")
+	}
+	_, err := w.out.WriteString("</tr>\n</thead>\n<tbody>\n")
+	return err
+}
+
+func (w *htmlWriter) Row(cells []model.Cell) error {
+	w.out.WriteString("<tr>")
+	for _, cell := range cells {
+		if cell.Null {
+			w.out.WriteString(`<td class="null">NULL</td>`)
+		} else {
+			w.out.WriteString("<td>" + html.EscapeString(cell.Text()) + "</td>")
+		}
+	}
+	_, err := w.out.WriteString("</tr>\n")
+	return err
+}
+
+func (w *htmlWriter) Close() error {
+	w.out.WriteString("</tbody>\n</table>\n</body>\n</html>\n")
+	return w.out.Flush()
+}
@@ -0,0 +1,110 @@
+package export
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ddoemonn/go-dot-dot/internal/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// goldenColumns and goldenRows cover the values each format has to escape or
+// tell apart: NULL and the string "NULL", empty strings, quotes, separators,
+// line breaks, backslashes and markup
+var goldenColumns = []string{"id", "note", `say "hi"`}
+
+var goldenRows = [][]model.Cell{
+	{{Value: "1"}, {Null: true}, {Value: "NULL"}},
+	{{Value: "2"}, {Value: ""}, {Value: `she said "hi", then left`}},
+	{{Value: "3"}, {Value: "line one\nline two\r\nline three"}, {Value: "tab\there"}},
+	{{Value: "4"}, {Value: "a | b || c"}, {Value: `C:\temp\new`}},
+	{{Value: "5"}, {Value: `<b>bold</b> & "quoted" 'single'`}, {Value: "*star* _under_ `code`"}},
+	{{Value: "6"}, {Value: `\.`}, {Value: `\N`}},
+}
+
+func TestWriters(t *testing.T) {
+	for _, format := range []Format{CSV, TSV, JSON, NDJSON, Markdown, HTML} {
+		t.Run(string(format), func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewWriter(&out, format, "public.<notes>")
+			if err := w.Header(goldenColumns); err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range goldenRows {
+				if err := w.Row(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join("testdata", "rows."+string(format))
+			if *update {
+				if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s:\n%s", path, out.String())
+			}
+		})
+	}
+}
+
+func TestEmptyJSON(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, JSON, "")
+	if err := w.Header(goldenColumns); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "[]\n" {
+		t.Errorf("empty JSON export = %q, want %q", got, "[]\n")
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := map[string]Format{
+		"out.csv": CSV, "OUT.TSV": TSV, "a.json": JSON, "a.jsonl": NDJSON,
+		"a.ndjson": NDJSON, "a.md": Markdown, "a.markdown": Markdown, "a.htm": HTML, "dir.v2/a.html": HTML,
+	}
+	for path, want := range tests {
+		if got, err := FormatFor(path); err != nil || got != want {
+			t.Errorf("FormatFor(%q) = %q, %v, want %q", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"out", "out.txt", "csv"} {
+		if _, err := FormatFor(path); err == nil {
+			t.Errorf("FormatFor(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestRepeatedColumnNames(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, NDJSON, "")
+	if err := w.Header([]string{"?column?", "?column?", "?column? (2)"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Row([]model.Cell{{Value: "1"}, {Value: "2"}, {Value: "3"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"?column?": "1", "?column? (2)": "2", "?column? (2) (2)": "3"}` + "\n"
+	if got := out.String(); got != want {
+		t.Errorf("NDJSON export = %q, want %q", got, want)
+	}
+}
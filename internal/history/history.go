@@ -0,0 +1,175 @@
+// Package history keeps a log of the statements run in the SQL editor. The
+// log is a JSON Lines file in the user data directory, shared by all
+// connections; each entry records the connection it ran on.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ddoemonn/go-dot-dot/internal/config"
+)
+
+// Entry is one executed statement
+type Entry struct {
+	SQL        string        `json:"sql"`
+	Connection string        `json:"connection"` // Profile name, or user@host:port/database without a profile
+	Time       time.Time     `json:"time"`
+	Duration   time.Duration `json:"duration"`
+	Rows       int64         `json:"rows"`            // Rows returned or affected
+	Error      string        `json:"error,omitempty"` // Empty when the statement succeeded
+}
+
+// Path returns the location of the history file
+func Path() (string, error) {
+	dir, err := config.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "history.jsonl"), nil
+}
+
+// Append adds an entry to the end of the history
+func Append(entry Entry) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("error creating data directory: %w", err)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening history: %w", err)
+	}
+	// A single write keeps lines from concurrent sessions intact
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	return file.Close()
+}
+
+// Load returns the history of a connection, newest first
+func Load(connection string) ([]Entry, error) {
+	entries, err := loadAll()
+	if err != nil {
+		return nil, err
+	}
+	var matching []Entry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Connection == connection {
+			matching = append(matching, entries[i])
+		}
+	}
+	return matching, nil
+}
+
+// Delete removes a single entry, identified by its connection and time
+func Delete(entry Entry) error {
+	return rewrite(func(e Entry) bool {
+		return e.Connection != entry.Connection || !e.Time.Equal(entry.Time)
+	})
+}
+
+// Prune removes the whole history of a connection
+func Prune(connection string) error {
+	return rewrite(func(e Entry) bool {
+		return e.Connection != connection
+	})
+}
+
+// loadAll reads every entry in file order. A missing file is an empty
+// history; lines that can't be parsed are skipped.
+func loadAll() ([]Entry, error) {
+	lines, err := readLines()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, line := range lines {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readLines reads the lines of the history file; a missing file has none
+func readLines() ([][]byte, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	defer file.Close()
+
+	var lines [][]byte
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			lines = append(lines, append([]byte(nil), scanner.Bytes()...))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %w", err)
+	}
+	return lines, nil
+}
+
+// rewrite replaces the history file with the lines of the entries for which
+// keep is true. Lines that can't be parsed, say written by another version,
+// are kept as they are.
+func rewrite(keep func(Entry) bool) error {
+	lines, err := readLines()
+	if err != nil || lines == nil {
+		return err
+	}
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), "history-*.jsonl")
+	if err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	writer := bufio.NewWriter(temp)
+	for _, line := range lines {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err == nil && !keep(entry) {
+			continue
+		}
+		writer.Write(line)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("error writing history: %w", err)
+	}
+	return os.Rename(temp.Name(), path)
+}
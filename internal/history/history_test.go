@@ -0,0 +1,131 @@
+package history
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// useTempHistory points the history file at a fresh directory
+func useTempHistory(t *testing.T) string {
+	t.Helper()
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	path, err := Path()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+var start = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// appendEntries adds one entry per statement, a second apart, all on a
+// connection
+func appendEntries(t *testing.T, connection string, statements ...string) {
+	t.Helper()
+	for i, sql := range statements {
+		entry := Entry{SQL: sql, Connection: connection, Time: start.Add(time.Duration(i) * time.Second)}
+		if err := Append(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// statements returns the SQL of the history of a connection, newest first
+func statements(t *testing.T, connection string) []string {
+	t.Helper()
+	entries, err := Load(connection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql := []string{}
+	for _, entry := range entries {
+		sql = append(sql, entry.SQL)
+	}
+	return sql
+}
+
+func TestLoad(t *testing.T) {
+	useTempHistory(t)
+	if got := statements(t, "prod"); len(got) != 0 {
+		t.Fatalf("Load without a history file = %q, want nothing", got)
+	}
+
+	appendEntries(t, "prod", "SELECT 1", "SELECT 2")
+	appendEntries(t, "alice@h:5432/app", "SELECT 3")
+	appendEntries(t, "prod", "SELECT 4")
+
+	if got, want := statements(t, "prod"), []string{"SELECT 4", "SELECT 2", "SELECT 1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Load(prod) = %q, want %q", got, want)
+	}
+	if got, want := statements(t, "alice@h:5432/app"), []string{"SELECT 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Load(alice@h:5432/app) = %q, want %q", got, want)
+	}
+	if got := statements(t, "staging"); len(got) != 0 {
+		t.Errorf("Load(staging) = %q, want nothing", got)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	useTempHistory(t)
+	appendEntries(t, "prod", "SELECT 1", "SELECT 2")
+	appendEntries(t, "staging", "SELECT 3", "SELECT 4")
+
+	// The same time on another connection is another entry
+	if err := Delete(Entry{Connection: "prod", Time: start}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := statements(t, "prod"), []string{"SELECT 2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Load(prod) = %q, want %q", got, want)
+	}
+	if got, want := statements(t, "staging"), []string{"SELECT 4", "SELECT 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Load(staging) = %q, want %q", got, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	useTempHistory(t)
+	if err := Prune("prod"); err != nil {
+		t.Fatalf("Prune without a history file: %v", err)
+	}
+
+	appendEntries(t, "prod", "SELECT 1", "SELECT 2")
+	appendEntries(t, "staging", "SELECT 3")
+	if err := Prune("prod"); err != nil {
+		t.Fatal(err)
+	}
+	if got := statements(t, "prod"); len(got) != 0 {
+		t.Errorf("Load(prod) = %q, want nothing", got)
+	}
+	if got, want := statements(t, "staging"), []string{"SELECT 3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Load(staging) = %q, want %q", got, want)
+	}
+}
+
+func TestRewriteKeepsOtherLines(t *testing.T) {
+	path := useTempHistory(t)
+	appendEntries(t, "prod", "SELECT 1")
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A line that doesn't parse, and one with a field this version doesn't know
+	other := "{\"sql\": \"SELECT 2\", \"connection\": \"staging\", \"time\": \"not a time\"}\n" +
+		"{\"sql\":\"SELECT 3\",\"connection\":\"staging\",\"time\":\"2024-05-01T12:00:00Z\",\"tags\":[\"x\"]}\n"
+	if _, err := file.WriteString(other); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if err := Prune("prod"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != other {
+		t.Errorf("history after Prune =\n%s\nwant\n%s", content, other)
+	}
+}
@@ -1,10 +1,18 @@
 package model
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -12,43 +20,450 @@ import (
 type Model struct {
 	Pool                   *pgxpool.Pool
 	TableList              list.Model
+	ProfileList            list.Model
+	ActiveProfile          string // Name of the connection profile in use, empty for .env settings
+	PasswordPrompt         string // Profile waiting for its password in the connection picker
+	PasswordInput          textinput.Model
 	TableData              table.Model
-	SelectedTable          string
-	Tables                 []string
+	SelectedTable          TableItem
+	Tables                 []TableItem
+	SavedQueries           []SavedQueryItem // Listed above the relations in the sidebar
 	ColumnNames            []string
-	Data                   [][]string
-	FilteredData           [][]string
+	Data                   [][]Cell
+	FilteredData           [][]Cell
 	Width                  int
 	Height                 int
-	Focused                int // 0: table list, 1: table data, 2: detail view
+	Focused                int // One of the Focus* views below
 	SearchMode             bool
 	SearchInput            textinput.Model
 	SearchQuery            string
+	ServerSearch           bool // Push searches to PostgreSQL instead of filtering loaded rows
+	SortKeys               []SortKey
 	ShowHelp               bool
 	Help                   help.Model
 	Err                    error
 	SelectedRow            int
-	SelectedRowData        map[string]string // Column name -> value
+	SelectedRowData        map[string]Cell // Column name -> value
+	DetailReturn           int             // View that Esc returns to from the detail view
+	DetailCursor           int             // Field selected in the detail view, see ui.DetailFields
+	Editing                bool            // The cell editor has the keyboard
+	EditColumn             string
+	EditInput              textinput.Model
 	ConnectionDetails      string
-	HorizontalScrollOffset int // Track horizontal scroll position
+	ReadOnly               bool   // The session can't change data; editing keys are disabled
+	StatusMessage          string // Transient feedback from the last action
+	Loading                string // Description of the query in flight, empty when idle
+	Spinner                spinner.Model
+	HorizontalScrollOffset int             // Track horizontal scroll position
+	ColumnCursor           int             // Column of the data grid that sorting and editing apply to
+	HiddenColumns          map[string]bool // Columns left out of the data grid and its exports, by name
+	Paging                 PageState
+
+	// Foreign key navigation
+	ForeignKeys     []ForeignKey // References the selected relation makes
+	ReferencingKeys []ForeignKey // References other relations make to it
+	Breadcrumbs     []string     // Relations followed to reach the selected one, oldest first
+	ReferenceList   list.Model   // Picker for the referencing rows (FocusReferences)
+
+	// Table view tabs besides the data grid
+	TableTab int         // Index into TableTabs
+	TabTable table.Model // Grid of the tab shown, when it isn't the data
+	TabEmpty string      // Shown instead of an empty grid, e.g. "No indexes"
+
+	// SQL editor (FocusQuery)
+	QueryEditor         textarea.Model
+	QueryResult         *QueryResult // Result of the last successful statement
+	QueryError          string       // Formatted error of the last failed statement
+	QueryTable          table.Model
+	QueryResultsFocused bool // Keys go to the result grid instead of the editor
+	QueryScrollOffset   int  // Horizontal scroll position of the result grid
+
+	HistoryList list.Model // Query history of the current connection (FocusHistory)
+
+	// Statement parameters form (FocusParams)
+	ParamTitle  string
+	ParamSource string   // Statement as written, with $n or :name parameters
+	ParamSQL    string   // Statement with every parameter as $n, as sent to the server
+	ParamNames  []string // Name of each $n placeholder, in order
+	ParamInputs []textinput.Model
+	ParamFocus  int
+	ParamReturn int // View that Esc returns to
+
+	// Insert form (FocusInsert)
+	InsertColumns []Column // Writable columns of the selected table
+	InsertInputs  []textinput.Model
+	InsertNull    []bool // The field inserts NULL rather than its input's value
+	InsertFocus   int
+	InsertError   string // Why the last insert failed, shown in the form
+
+	// Staged changes (FocusChanges)
+	Staging       bool     // Edits are collected into a changeset rather than applied at once
+	PendingSQL    []string // Statement for each pending change, for review
+	ChangesCursor int
+	ChangesError  string // Why the last commit failed
+
+	// DDL pane (FocusDDL)
+	DDLTitle  string
+	DDLView   viewport.Model
+	DDLReturn int // View that Esc returns to
+
+	FunctionList list.Model // Picker of functions to show the DDL of (FocusFunctions)
+
+	// File name prompt for saving output; see App.promptSave
+	SavePrompt string // What is being saved, empty when the prompt is closed
+	SaveInput  textinput.Model
+}
+
+// Cell is a single value of a result row. Null marks an SQL NULL, which is
+// distinct from a text value that happens to read "NULL".
+type Cell struct {
+	Value string
+	Null  bool
+	Raw   *string // Server text form, when Value was reformatted for display
+}
+
+// NullCell returns a cell holding SQL NULL
+func NullCell() Cell {
+	return Cell{Null: true}
+}
+
+// Text returns the value in PostgreSQL's text form, as it must be sent back
+// to the server
+func (c Cell) Text() string {
+	if c.Raw != nil {
+		return *c.Raw
+	}
+	return c.Value
+}
+
+// String returns the cell value, or "NULL" for SQL NULL
+func (c Cell) String() string {
+	if c.Null {
+		return "NULL"
+	}
+	return c.Value
+}
+
+// PageState tracks lazy loading of the selected relation's rows
+type PageState struct {
+	KeyColumns []string  // Primary or unique key columns, for keyset paging and editing; empty means OFFSET paging
+	LastKey    []any     // Key values of the last loaded row
+	HasMore    bool      // Whether more rows are available on the server
+	Loading    bool      // Whether a page fetch is in flight
+	TotalRows  int64     // Exact or estimated row count, -1 when unknown
+	Estimated  bool      // Whether TotalRows is a planner estimate
+	Filter     string    // Search query applied server-side to every page, empty for none
+	Match      *RowMatch // Restricts every page to the rows referenced by a followed key
+	Generation int       // Incremented whenever the loaded rows are reset, to drop stale pages
+}
+
+// QueryResult holds the outcome of a statement run in the SQL editor
+type QueryResult struct {
+	SQL          string
+	Columns      []string
+	Rows         [][]Cell
+	CommandTag   string // e.g. "SELECT 3" or "UPDATE 1"
+	RowsAffected int64
+	Truncated    bool // Only the first rows of the result were kept
+	Duration     time.Duration
+}
+
+// Column describes a column of a relation for the insert form
+type Column struct {
+	Name      string
+	Type      string // Data type, e.g. "character varying(64)"
+	Default   string // Default expression; empty for none
+	Nullable  bool
+	Identity  bool
+	Generated bool // Identity ALWAYS or generated column, which can't be written
+}
+
+// ColumnDetail describes a column of a relation for the structure tab
+type ColumnDetail struct {
+	Name      string
+	Type      string // As format_type renders it, e.g. "character varying(64)"
+	Default   string
+	NotNull   bool
+	Identity  string // "ALWAYS" or "BY DEFAULT" for identity columns
+	Generated string // Expression of a stored generated column
+	Collation string // Set only when it differs from the type's default
+	Comment   string
+}
+
+// IndexInfo describes an index for the indexes tab
+type IndexInfo struct {
+	Name          string
+	Definition    string // CREATE INDEX statement from pg_get_indexdef
+	Size          string // As pg_size_pretty renders it
+	Primary       bool
+	Unique        bool
+	Partial       bool
+	Valid         bool
+	Scans         int64 // Usage counts from pg_stat_user_indexes
+	TuplesRead    int64
+	TuplesFetched int64
+}
+
+// ConstraintInfo describes a constraint for the constraints tab
+type ConstraintInfo struct {
+	Name       string
+	Type       string // e.g. "PRIMARY KEY" or "CHECK"
+	Definition string // From pg_get_constraintdef
+}
+
+// TriggerInfo describes a trigger for the triggers tab
+type TriggerInfo struct {
+	Name       string
+	Enabled    string // "enabled", "disabled", "replica" or "always"
+	Function   string // Trigger function with its argument types
+	Definition string // CREATE TRIGGER statement from pg_get_triggerdef
+}
+
+// ForeignKey is a foreign key constraint, from the referencing relation's
+// columns to the referenced relation's
+type ForeignKey struct {
+	Name       string
+	Table      TableItem // Referencing relation
+	Columns    []string
+	RefTable   TableItem // Referenced relation
+	RefColumns []string
 }
 
-// TableItem represents a database table in the list
+// RowMatch restricts the table view to the rows whose columns hold the
+// given values (in text form), as when following a foreign key
+type RowMatch struct {
+	Columns []string
+	Values  []string
+}
+
+// String renders the match as a condition, e.g. "id = 5"
+func (m RowMatch) String() string {
+	terms := make([]string, len(m.Columns))
+	for i, column := range m.Columns {
+		terms[i] = column + " = " + m.Values[i]
+	}
+	return strings.Join(terms, " AND ")
+}
+
+// SortKey is one column of an ORDER BY
+type SortKey struct {
+	Column     string
+	Descending bool
+}
+
+// Views that can hold the focus
+const (
+	FocusTableList   = 0
+	FocusTableData   = 1
+	FocusDetail      = 2
+	FocusConnections = 3
+	FocusQuery       = 4
+	FocusHistory     = 5
+	FocusParams      = 6
+	FocusInsert      = 7
+	FocusChanges     = 8
+	FocusReferences  = 9
+	FocusDDL         = 10
+	FocusFunctions   = 11
+)
+
+// Tabs of the table view
+const (
+	TabData        = 0
+	TabStructure   = 1
+	TabIndexes     = 2
+	TabConstraints = 3
+	TabTriggers    = 4
+)
+
+// TableTabs are the titles of the table view tabs, in order
+var TableTabs = []string{"Data", "Structure", "Indexes", "Constraints", "Triggers"}
+
+// Relation kinds as stored in pg_class.relkind
+const (
+	KindTable            = "r"
+	KindView             = "v"
+	KindMaterializedView = "m"
+	KindForeignTable     = "f"
+	KindPartitionedTable = "p"
+)
+
+// TableItem represents a database relation (table, view, ...) in the list
 type TableItem struct {
-	Name string
+	Schema string
+	Name   string
+	Kind   string // pg_class.relkind
+}
+
+// KindLabel returns a human-readable marker for the relation kind
+func (i TableItem) KindLabel() string {
+	switch i.Kind {
+	case KindTable:
+		return "table"
+	case KindView:
+		return "view"
+	case KindMaterializedView:
+		return "materialized view"
+	case KindForeignTable:
+		return "foreign table"
+	case KindPartitionedTable:
+		return "partitioned table"
+	default:
+		return ""
+	}
+}
+
+// QualifiedName returns the schema-qualified name for display
+func (i TableItem) QualifiedName() string {
+	if i.Schema == "" {
+		return i.Name
+	}
+	return i.Schema + "." + i.Name
+}
+
+// Identifier returns the properly quoted schema.table identifier for use in SQL
+func (i TableItem) Identifier() string {
+	if i.Schema == "" {
+		return pgx.Identifier{i.Name}.Sanitize()
+	}
+	return pgx.Identifier{i.Schema, i.Name}.Sanitize()
 }
 
 // FilterValue returns the value to filter on
 func (i TableItem) FilterValue() string {
-	return i.Name
+	return i.QualifiedName()
 }
 
 // Title returns the title of the item
 func (i TableItem) Title() string {
-	return i.Name
+	return i.QualifiedName()
 }
 
 // Description returns the description of the item
 func (i TableItem) Description() string {
-	return ""
+	return i.KindLabel()
+}
+
+// SavedQueryItem represents a saved query in the sidebar, listed above the
+// relations
+type SavedQueryItem struct {
+	Name    string
+	Summary string // Optional description from the saved queries file
+	SQL     string
+}
+
+// FilterValue returns the value to filter on
+func (i SavedQueryItem) FilterValue() string {
+	return i.Name
+}
+
+// Title returns the title of the item
+func (i SavedQueryItem) Title() string {
+	return "» " + i.Name
+}
+
+// Description returns the description of the item
+func (i SavedQueryItem) Description() string {
+	if i.Summary != "" {
+		return i.Summary
+	}
+	return "saved query"
+}
+
+// ProfileItem represents a connection profile in the connection picker
+type ProfileItem struct {
+	Name    string
+	Details string
+}
+
+// FilterValue returns the value to filter on
+func (i ProfileItem) FilterValue() string {
+	return i.Name
+}
+
+// Title returns the title of the item
+func (i ProfileItem) Title() string {
+	return i.Name
+}
+
+// Description returns the description of the item
+func (i ProfileItem) Description() string {
+	return i.Details
+}
+
+// FunctionItem represents a function or procedure in the functions picker
+type FunctionItem struct {
+	Schema    string
+	Name      string
+	Signature string // As regprocedure renders it, which identifies it
+	Arguments string
+	Procedure bool
+}
+
+// FilterValue returns the value to filter on
+func (i FunctionItem) FilterValue() string {
+	return i.Schema + "." + i.Name
+}
+
+// Title returns the title of the item
+func (i FunctionItem) Title() string {
+	return fmt.Sprintf("%s.%s(%s)", i.Schema, i.Name, i.Arguments)
+}
+
+// Description returns the description of the item
+func (i FunctionItem) Description() string {
+	if i.Procedure {
+		return "procedure"
+	}
+	return "function"
+}
+
+// ReferenceItem represents a foreign key of another relation in the
+// referencing rows picker
+type ReferenceItem struct {
+	Key ForeignKey
+}
+
+// FilterValue returns the value to filter on
+func (i ReferenceItem) FilterValue() string {
+	return i.Key.Table.QualifiedName()
+}
+
+// Title returns the title of the item
+func (i ReferenceItem) Title() string {
+	return i.Key.Table.QualifiedName()
+}
+
+// Description returns the description of the item
+func (i ReferenceItem) Description() string {
+	return fmt.Sprintf("(%s) via %s", strings.Join(i.Key.Columns, ", "), i.Key.Name)
+}
+
+// HistoryItem represents an executed statement in the query history browser
+type HistoryItem struct {
+	SQL        string
+	Connection string
+	Time       time.Time
+	Duration   time.Duration
+	Rows       int64
+	Error      string // Empty when the statement succeeded
+}
+
+// FilterValue returns the value to filter on
+func (i HistoryItem) FilterValue() string {
+	return i.SQL
+}
+
+// Title returns the statement, flattened to one line
+func (i HistoryItem) Title() string {
+	return strings.Join(strings.Fields(i.SQL), " ")
+}
+
+// Description returns when the statement ran and how it went
+func (i HistoryItem) Description() string {
+	when := i.Time.Local().Format("2006-01-02 15:04")
+	if i.Error != "" {
+		return fmt.Sprintf("%s · failed: %s", when, i.Error)
+	}
+	return fmt.Sprintf("%s · %d rows · %s", when, i.Rows, i.Duration.Round(time.Millisecond))
 }
@@ -1,25 +1,105 @@
 package ui
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/utils"
 )
 
-// CreateTableItems converts a slice of table names to list items
-func CreateTableItems(tables []string) []list.Item {
-	items := make([]list.Item, len(tables))
-	for i, table := range tables {
-		items[i] = model.TableItem{Name: table}
+// CreateTableItems converts saved queries and tables to list items, with the
+// saved queries first
+func CreateTableItems(tables []model.TableItem, queries []model.SavedQueryItem) []list.Item {
+	items := make([]list.Item, 0, len(queries)+len(tables))
+	for _, query := range queries {
+		items = append(items, query)
+	}
+	for _, table := range tables {
+		items = append(items, table)
+	}
+	return items
+}
+
+// CreateTableList creates a styled list for table selection, with each table
+// prefixed by its schema and the saved queries above them
+func CreateTableList(tables []model.TableItem, queries []model.SavedQueryItem, styles *Styles) list.Model {
+	return createList(CreateTableItems(tables, queries), styles)
+}
+
+// CreateProfileItems converts a slice of connection profiles to list items
+func CreateProfileItems(profiles []model.ProfileItem) []list.Item {
+	items := make([]list.Item, len(profiles))
+	for i, profile := range profiles {
+		items[i] = profile
+	}
+	return items
+}
+
+// CreateProfileList creates a styled list for the connection picker
+func CreateProfileList(profiles []model.ProfileItem, styles *Styles) list.Model {
+	return createList(CreateProfileItems(profiles), styles)
+}
+
+// CreateReferenceItems converts foreign keys to items of the referencing
+// rows picker
+func CreateReferenceItems(keys []model.ForeignKey) []list.Item {
+	items := make([]list.Item, len(keys))
+	for i, key := range keys {
+		items[i] = model.ReferenceItem{Key: key}
+	}
+	return items
+}
+
+// CreateFunctionItems converts functions to items of the functions picker
+func CreateFunctionItems(functions []model.FunctionItem) []list.Item {
+	items := make([]list.Item, len(functions))
+	for i, function := range functions {
+		items[i] = function
 	}
 	return items
 }
 
-// CreateTableList creates a styled list for table selection
-func CreateTableList(tables []string, styles *Styles) list.Model {
+// CreateHistoryItems converts query history entries to list items
+func CreateHistoryItems(entries []model.HistoryItem) []list.Item {
+	items := make([]list.Item, len(entries))
+	for i, entry := range entries {
+		items[i] = entry
+	}
+	return items
+}
+
+// CreateHistoryList creates the query history browser, with fuzzy filtering
+// on the statement text
+func CreateHistoryList(styles *Styles) list.Model {
+	historyList := createList(nil, styles)
+	historyList.SetFilteringEnabled(true)
+	return historyList
+}
+
+// CreateReferenceList creates the picker for the rows referencing a row
+func CreateReferenceList(styles *Styles) list.Model {
+	return createList(nil, styles)
+}
+
+// CreateFunctionList creates the picker of functions to show the DDL of,
+// with filtering on their names
+func CreateFunctionList(styles *Styles) list.Model {
+	functionList := createList(nil, styles)
+	functionList.SetFilteringEnabled(true)
+	return functionList
+}
+
+// createList creates a list with the application's item styling
+func createList(items []list.Item, styles *Styles) list.Model {
 	listDelegate := list.NewDefaultDelegate()
 	listDelegate.SetSpacing(0) // Reduce the spacing between items to 0
 	listDelegate.Styles.SelectedTitle = listDelegate.Styles.SelectedTitle.
@@ -28,7 +108,7 @@ func CreateTableList(tables []string, styles *Styles) list.Model {
 	listDelegate.Styles.NormalTitle = listDelegate.Styles.NormalTitle.
 		Foreground(lipgloss.Color(ColorText))
 
-	tableList := list.New(CreateTableItems(tables), listDelegate, 0, 0)
+	tableList := list.New(items, listDelegate, 0, 0)
 	tableList.SetShowStatusBar(false)
 	tableList.SetFilteringEnabled(false)
 	tableList.SetShowHelp(false)
@@ -37,6 +117,21 @@ func CreateTableList(tables []string, styles *Styles) list.Model {
 	return tableList
 }
 
+// CreateSpinner creates the spinner shown while a query is running
+func CreateSpinner() spinner.Model {
+	sp := spinner.New()
+	sp.Spinner = spinner.Dot
+	sp.Style = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorAccent))
+	return sp
+}
+
+// CreateDDLView creates the scrollable pane showing generated DDL
+func CreateDDLView(ddl string, width, height int) viewport.Model {
+	vp := viewport.New(width, height)
+	vp.SetContent(ddl)
+	return vp
+}
+
 // CreateSearchInput creates and configures a text input for search
 func CreateSearchInput() textinput.Model {
 	ti := textinput.New()
@@ -49,8 +144,69 @@ func CreateSearchInput() textinput.Model {
 	return ti
 }
 
-// CreateTableData creates a styled table based on column names and data
-func CreateTableData(columns []string, data [][]string, horizontalScrollOffset int) table.Model {
+// CreateQueryEditor creates the multi-line input of the SQL editor
+func CreateQueryEditor() textarea.Model {
+	ta := textarea.New()
+	ta.Placeholder = "SELECT ... (ctrl+s or F5 to run)"
+	ta.ShowLineNumbers = true
+	ta.CharLimit = 0
+	ta.MaxHeight = 0
+	ta.SetHeight(QueryEditorHeight)
+	ta.FocusedStyle.CursorLine = lipgloss.NewStyle()
+	ta.FocusedStyle.LineNumber = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorMuted))
+	ta.FocusedStyle.Text = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorText))
+	ta.Cursor.Style = lipgloss.NewStyle().Foreground(lipgloss.Color(ColorAccent))
+	return ta
+}
+
+// QueryEditorHeight is the number of lines shown in the SQL editor
+const QueryEditorHeight = 8
+
+// CreateEditInput creates the input for editing a cell, holding its value
+func CreateEditInput(value string) textinput.Model {
+	ti := CreateSearchInput()
+	ti.CharLimit = 0
+	ti.Width = 60
+	ti.SetValue(value)
+	ti.Focus()
+	return ti
+}
+
+// CreateParamInput creates the input for one statement parameter
+func CreateParamInput(value string) textinput.Model {
+	ti := CreateSearchInput()
+	ti.CharLimit = 0
+	ti.Width = 40
+	ti.Placeholder = "NULL"
+	ti.SetValue(value)
+	return ti
+}
+
+// CreateInsertInput creates the input for one column of the insert form,
+// hinting at what an empty input inserts
+func CreateInsertInput(column model.Column) textinput.Model {
+	ti := CreateSearchInput()
+	ti.CharLimit = 0
+	ti.Width = 40
+	switch {
+	case column.Default != "":
+		ti.Placeholder = "DEFAULT " + column.Default
+	case column.Identity:
+		ti.Placeholder = "generated by identity"
+	case column.Nullable:
+		ti.Placeholder = "NULL"
+	default:
+		ti.Placeholder = "required"
+	}
+	return ti
+}
+
+// NullMarker is how SQL NULL is shown in the data grid, so it cannot be
+// mistaken for a text value reading "NULL"
+const NullMarker = "∅"
+
+// CreateTableRows converts data into table rows, truncating long values
+func CreateTableRows(columns []string, data [][]model.Cell) []table.Row {
 	rows := make([]table.Row, len(data))
 	for i, d := range data {
 		// Make sure we don't go out of bounds if the data has more columns than headers
@@ -61,23 +217,129 @@ func CreateTableData(columns []string, data [][]string, horizontalScrollOffset i
 
 		row := make(table.Row, maxCols)
 		for j := 0; j < maxCols; j++ {
-			if j < len(d) {
-				// Truncate long values to prevent UI issues
-				if len(d[j]) > 100 {
-					row[j] = d[j][:97] + "..."
-				} else {
-					row[j] = d[j]
-				}
-			} else {
-				row[j] = ""
-			}
+			row[j] = CellText(d[j])
 		}
 		rows[i] = row
 	}
+	return rows
+}
+
+// CellText returns how a value is shown in the data grid, truncating long
+// values to prevent UI issues
+func CellText(cell model.Cell) string {
+	if cell.Null {
+		return NullMarker
+	}
+	if len(cell.Value) > 100 {
+		return cell.Value[:97] + "..."
+	}
+	return cell.Value
+}
+
+// CreateStructureTable creates the grid of the structure tab, one row per
+// column of the relation
+func CreateStructureTable(columns []model.ColumnDetail) table.Model {
+	headers := []string{"#", "Column", "Type", "Null", "Default", "Identity / Generated", "Collation", "Comment"}
+	rows := make([][]model.Cell, len(columns))
+	for i, c := range columns {
+		nullable := "NULL"
+		if c.NotNull {
+			nullable = "NOT NULL"
+		}
+		status := ""
+		switch {
+		case c.Identity != "":
+			status = "GENERATED " + c.Identity + " AS IDENTITY"
+		case c.Generated != "":
+			status = "GENERATED ALWAYS AS (" + c.Generated + ") STORED"
+		}
+		rows[i] = []model.Cell{
+			{Value: fmt.Sprint(i + 1)}, {Value: c.Name}, {Value: c.Type}, {Value: nullable},
+			{Value: c.Default}, {Value: status}, {Value: c.Collation}, {Value: c.Comment},
+		}
+	}
+	return CreateTableData(headers, rows, 0, nil)
+}
+
+// CreateIndexTable creates the grid of the indexes tab
+func CreateIndexTable(indexes []model.IndexInfo) table.Model {
+	headers := []string{"Index", "Kind", "Size", "Scans", "Tuples read", "Tuples fetched", "Definition"}
+	rows := make([][]model.Cell, len(indexes))
+	for i, index := range indexes {
+		var kind []string
+		switch {
+		case index.Primary:
+			kind = append(kind, "primary")
+		case index.Unique:
+			kind = append(kind, "unique")
+		}
+		if index.Partial {
+			kind = append(kind, "partial")
+		}
+		if !index.Valid {
+			kind = append(kind, "INVALID")
+		}
+		rows[i] = []model.Cell{
+			{Value: index.Name}, {Value: strings.Join(kind, ", ")}, {Value: index.Size},
+			{Value: fmt.Sprint(index.Scans)}, {Value: fmt.Sprint(index.TuplesRead)}, {Value: fmt.Sprint(index.TuplesFetched)},
+			{Value: index.Definition},
+		}
+	}
+	return CreateTableData(headers, rows, 0, nil)
+}
+
+// CreateConstraintTable creates the grid of the constraints tab
+func CreateConstraintTable(constraints []model.ConstraintInfo) table.Model {
+	headers := []string{"Constraint", "Type", "Definition"}
+	rows := make([][]model.Cell, len(constraints))
+	for i, c := range constraints {
+		rows[i] = []model.Cell{{Value: c.Name}, {Value: c.Type}, {Value: c.Definition}}
+	}
+	return CreateTableData(headers, rows, 0, nil)
+}
+
+// CreateTriggerTable creates the grid of the triggers tab
+func CreateTriggerTable(triggers []model.TriggerInfo) table.Model {
+	headers := []string{"Trigger", "State", "Function", "Definition"}
+	rows := make([][]model.Cell, len(triggers))
+	for i, t := range triggers {
+		rows[i] = []model.Cell{{Value: t.Name}, {Value: t.Enabled}, {Value: t.Function}, {Value: t.Definition}}
+	}
+	return CreateTableData(headers, rows, 0, nil)
+}
+
+// Markers prefixed to the cells of selected rows and pending changes
+const (
+	RowMarker     = "● "
+	InsertMarker  = "+ "
+	DeleteMarker  = "✗ "
+	ChangedMarker = "✎ "
+)
+
+// MarkRow prefixes a marker to the cell of a grid row under the column cursor
+func MarkRow(row table.Row, columnCursor int, marker string) {
+	if columnCursor < len(row) {
+		row[columnCursor] = marker + row[columnCursor]
+	}
+}
+
+// ColumnMarker flags the header of the column under the column cursor
+const ColumnMarker = "▸ "
+
+// CreateTableData creates a styled table based on column names and data,
+// marking the sorted columns in the header
+func CreateTableData(columns []string, data [][]model.Cell, horizontalScrollOffset int, sortKeys []model.SortKey) table.Model {
+	return CreateDataGrid(columns, data, horizontalScrollOffset, -1, sortKeys, nil)
+}
+
+// CreateDataGrid creates the data grid of the table view, which also flags
+// the column under the column cursor in the header
+func CreateDataGrid(columns []string, data [][]model.Cell, horizontalScrollOffset int, columnCursor int, sortKeys []model.SortKey, hidden map[string]bool) table.Model {
+	rows := CreateTableRows(columns, data)
 
 	// Create table columns with horizontal scrolling
 	t := table.New(
-		table.WithColumns(makeColumns(columns, horizontalScrollOffset)),
+		table.WithColumns(makeColumns(columns, horizontalScrollOffset, columnCursor, sortKeys, hidden)),
 		table.WithRows(rows),
 		table.WithFocused(true),
 		table.WithHeight(20),
@@ -104,34 +366,77 @@ func CreateTableData(columns []string, data [][]string, horizontalScrollOffset i
 	return t
 }
 
-// Create table columns with appropriate widths and horizontal scrolling
-func makeColumns(headers []string, horizontalScrollOffset int) []table.Column {
+// Create table columns with appropriate widths, horizontal scrolling, sort
+// indicators and the column cursor
+func makeColumns(headers []string, horizontalScrollOffset int, columnCursor int, sortKeys []model.SortKey, hidden map[string]bool) []table.Column {
 	columns := make([]table.Column, len(headers))
 
-	// Apply horizontal scrolling offset
-	visibleHeaders := headers
-	if horizontalScrollOffset > 0 && horizontalScrollOffset < len(headers) {
-		visibleHeaders = headers[horizontalScrollOffset:]
+	// Columns scrolled off to the left and hidden columns keep a zero width
+	for i := horizontalScrollOffset; i < len(headers); i++ {
+		if hidden[headers[i]] {
+			continue
+		}
+		title := columnTitle(headers, i, columnCursor, sortKeys)
+		columns[i] = table.Column{
+			Title: title,
+			Width: columnWidth(title),
+		}
 	}
 
-	for i, header := range visibleHeaders {
-		// Adjust width based on header length
-		width := len(header) + 8
-		if width < 16 {
-			width = 16
-		} else if width > 40 {
-			width = 40
-		}
+	return columns
+}
 
-		// Calculate the actual index in the original headers array
-		actualIndex := i + horizontalScrollOffset
-		if actualIndex < len(headers) {
-			columns[actualIndex] = table.Column{
-				Title: header,
-				Width: width,
-			}
+// columnTitle returns the header of a column with its markers
+func columnTitle(headers []string, index int, columnCursor int, sortKeys []model.SortKey) string {
+	title := headers[index] + sortIndicator(headers[index], sortKeys)
+	if index == columnCursor {
+		title = ColumnMarker + title
+	}
+	return title
+}
+
+// columnWidth sizes a column to its header, within bounds
+func columnWidth(title string) int {
+	// Adjust width based on header length
+	return utils.Min(utils.Max(len(title)+8, 16), 40)
+}
+
+// VisibleColumns returns how many columns, from the first one shown, fit in
+// a grid of the given width, counting the hidden columns among them, which
+// take no room. At least one column is always shown.
+func VisibleColumns(headers []string, horizontalScrollOffset int, columnCursor int, sortKeys []model.SortKey, hidden map[string]bool, width int) int {
+	used, count, shown := 0, 0, 0
+	for i := horizontalScrollOffset; i < len(headers); i++ {
+		if hidden[headers[i]] {
+			count++
+			continue
+		}
+		// Cells are padded by one space on each side
+		used += columnWidth(columnTitle(headers, i, columnCursor, sortKeys)) + 2
+		if used > width && shown > 0 {
+			break
 		}
+		count++
+		shown++
 	}
+	return count
+}
 
-	return columns
+// sortIndicator returns the arrow (and position, for multi-column sorts)
+// shown next to a sorted column's header
+func sortIndicator(column string, sortKeys []model.SortKey) string {
+	for i, sortKey := range sortKeys {
+		if sortKey.Column != column {
+			continue
+		}
+		arrow := " ▲"
+		if sortKey.Descending {
+			arrow = " ▼"
+		}
+		if len(sortKeys) > 1 {
+			arrow += fmt.Sprintf("%d", i+1)
+		}
+		return arrow
+	}
+	return ""
 }
@@ -23,6 +23,35 @@ type KeyMap struct {
 	End         key.Binding
 	ScrollLeft  key.Binding
 	ScrollRight key.Binding
+	RefreshView key.Binding
+	SearchScope key.Binding
+	Sort        key.Binding
+	AddSort     key.Binding
+	HideColumn  key.Binding
+	ShowColumns key.Binding
+	Cancel      key.Binding
+	Connections key.Binding
+	QueryEditor key.Binding
+	RunQuery    key.Binding
+	SwitchPane  key.Binding
+	History     key.Binding
+	Delete      key.Binding
+	DeleteRows  key.Binding
+	Prune       key.Binding
+	Edit        key.Binding
+	Insert      key.Binding
+	Mark        key.Binding
+	Stage       key.Binding
+	Review      key.Binding
+	Rollback    key.Binding
+	Follow      key.Binding
+	References  key.Binding
+	ShowDDL     key.Binding
+	Functions   key.Binding
+	Copy        key.Binding
+	WriteFile   key.Binding
+	Export      key.Binding
+	ExportAll   key.Binding
 }
 
 // NewKeyMap creates a new keymap with default bindings
@@ -69,8 +98,8 @@ func NewKeyMap() *KeyMap {
 			key.WithHelp("?", "toggle help"),
 		),
 		ViewDetails: key.NewBinding(
-			key.WithKeys("v", "space"),
-			key.WithHelp("v/space", "view details"),
+			key.WithKeys("v"),
+			key.WithHelp("v", "view details"),
 		),
 		PageUp: key.NewBinding(
 			key.WithKeys("pgup"),
@@ -96,6 +125,122 @@ func NewKeyMap() *KeyMap {
 			key.WithKeys("shift+right", "shift+l"),
 			key.WithHelp("shift+→/shift+l", "scroll right"),
 		),
+		RefreshView: key.NewBinding(
+			key.WithKeys("ctrl+r"),
+			key.WithHelp("ctrl+r", "refresh materialized view"),
+		),
+		SearchScope: key.NewBinding(
+			key.WithKeys("ctrl+t"),
+			key.WithHelp("ctrl+t", "toggle local/server search"),
+		),
+		Sort: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "sort by column"),
+		),
+		AddSort: key.NewBinding(
+			key.WithKeys("S"),
+			key.WithHelp("S", "add column to sort"),
+		),
+		HideColumn: key.NewBinding(
+			key.WithKeys("-"),
+			key.WithHelp("-", "hide column"),
+		),
+		ShowColumns: key.NewBinding(
+			key.WithKeys("+"),
+			key.WithHelp("+", "show all columns"),
+		),
+		Cancel: key.NewBinding(
+			key.WithKeys("ctrl+g"),
+			key.WithHelp("ctrl+g", "cancel query"),
+		),
+		Connections: key.NewBinding(
+			key.WithKeys("ctrl+p"),
+			key.WithHelp("ctrl+p", "switch connection"),
+		),
+		QueryEditor: key.NewBinding(
+			key.WithKeys("ctrl+e"),
+			key.WithHelp("ctrl+e", "sql editor"),
+		),
+		RunQuery: key.NewBinding(
+			key.WithKeys("ctrl+s", "f5"),
+			key.WithHelp("ctrl+s/f5", "run query"),
+		),
+		SwitchPane: key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "editor/results"),
+		),
+		History: key.NewBinding(
+			key.WithKeys("ctrl+o"),
+			key.WithHelp("ctrl+o", "query history"),
+		),
+		Delete: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "delete entry"),
+		),
+		DeleteRows: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "delete rows"),
+		),
+		Prune: key.NewBinding(
+			key.WithKeys("D"),
+			key.WithHelp("D", "clear connection history"),
+		),
+		Edit: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit cell"),
+		),
+		Insert: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "insert row"),
+		),
+		Mark: key.NewBinding(
+			key.WithKeys(" "),
+			key.WithHelp("space", "select row"),
+		),
+		Stage: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "toggle staged changes"),
+		),
+		Review: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "review changes"),
+		),
+		Rollback: key.NewBinding(
+			key.WithKeys("x"),
+			key.WithHelp("x", "roll back changes"),
+		),
+		Follow: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "follow foreign key"),
+		),
+		References: key.NewBinding(
+			key.WithKeys("r"),
+			key.WithHelp("r", "referencing rows"),
+		),
+		ShowDDL: key.NewBinding(
+			key.WithKeys("D"),
+			key.WithHelp("D", "show DDL"),
+		),
+		Functions: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", "function DDL"),
+		),
+		Copy: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "copy"),
+		),
+		WriteFile: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "write to file"),
+		),
+		Export: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "export view"),
+		),
+		ExportAll: key.NewBinding(
+			key.WithKeys("A"),
+			key.WithHelp("A", "export all rows"),
+		),
 	}
 }
 
@@ -108,7 +253,15 @@ func (k KeyMap) ShortHelp() []key.Binding {
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down, k.Left, k.Right, k.PageUp, k.PageDown},
-		{k.Home, k.End, k.Select, k.ViewDetails, k.Back},
-		{k.ScrollLeft, k.ScrollRight, k.Search, k.ClearSearch, k.Help, k.Quit},
+		{k.Home, k.End, k.Select, k.ViewDetails, k.Edit, k.Insert, k.DeleteRows, k.Mark, k.Stage, k.Review, k.Follow, k.References, k.ShowDDL, k.Functions, k.Copy, k.WriteFile, k.Export, k.ExportAll, k.Sort, k.AddSort, k.HideColumn, k.ShowColumns, k.Back, k.Connections, k.QueryEditor, k.History, k.Delete, k.Prune},
+		{k.ScrollLeft, k.ScrollRight, k.Search, k.SearchScope, k.ClearSearch, k.RefreshView, k.RunQuery, k.SwitchPane, k.Cancel, k.Help, k.Quit},
+	}
+}
+
+// SetReadOnly disables the bindings that change data or schema, which also
+// hides them from the help
+func (k *KeyMap) SetReadOnly(readOnly bool) {
+	for _, binding := range []*key.Binding{&k.Edit, &k.Insert, &k.DeleteRows, &k.Mark, &k.Stage, &k.Review, &k.Rollback, &k.RefreshView} {
+		binding.SetEnabled(!readOnly)
 	}
 }
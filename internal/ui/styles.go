@@ -30,6 +30,8 @@ type Styles struct {
 	Title         lipgloss.Style
 	StatusMessage lipgloss.Style
 	SearchPrompt  lipgloss.Style
+	Notice        lipgloss.Style
+	Error         lipgloss.Style
 	ColumnHeader  lipgloss.Style
 	Help          lipgloss.Style
 
@@ -40,8 +42,9 @@ type Styles struct {
 	DetailCard  lipgloss.Style
 
 	// Header styles
-	AppTitle lipgloss.Style
-	InfoBox  lipgloss.Style
+	AppTitle      lipgloss.Style
+	InfoBox       lipgloss.Style
+	ReadOnlyBadge lipgloss.Style
 
 	// Table styles
 	TableListHeader lipgloss.Style
@@ -88,6 +91,13 @@ func NewStyles() *Styles {
 	s.SearchPrompt = lipgloss.NewStyle().
 		Foreground(lipgloss.Color(ColorSecondary))
 
+	s.Notice = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(ColorWarning)).
+		Bold(true)
+
+	s.Error = lipgloss.NewStyle().
+		Foreground(lipgloss.Color(ColorAccent))
+
 	s.ColumnHeader = lipgloss.NewStyle().
 		Bold(true).
 		Foreground(lipgloss.Color(ColorAccent))
@@ -131,6 +141,14 @@ func NewStyles() *Styles {
 		MarginTop(1).
 		MarginBottom(1)
 
+	s.ReadOnlyBadge = lipgloss.NewStyle().
+		Bold(true).
+		Foreground(lipgloss.Color(ColorBackground)).
+		Background(lipgloss.Color(ColorAccent)).
+		Padding(1, 2).
+		MarginTop(1).
+		MarginLeft(1)
+
 	s.TableListHeader = lipgloss.NewStyle().
 		Background(lipgloss.Color(ColorMuted)).
 		Foreground(lipgloss.Color(ColorBackground)).
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/charmbracelet/lipgloss"
 
 	"github.com/ddoemonn/go-dot-dot/internal/model"
+	"github.com/ddoemonn/go-dot-dot/internal/utils"
 )
 
 // RenderView renders the main UI view
@@ -20,21 +22,75 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 	appTitle := styles.AppTitle.Width(m.Width).Render("PostgreSQL Database Explorer")
 
 	// Connection info badge
-	connectionInfo := styles.InfoBox.Render(fmt.Sprintf("🔌 %s", m.ConnectionDetails))
+	connectionText := m.ConnectionDetails
+	if m.ActiveProfile != "" {
+		connectionText = fmt.Sprintf("[%s] %s", m.ActiveProfile, connectionText)
+	}
+	connectionInfo := styles.InfoBox.Render(fmt.Sprintf("🔌 %s", connectionText))
+	if m.ReadOnly {
+		connectionInfo = lipgloss.JoinHorizontal(lipgloss.Top, connectionInfo, styles.ReadOnlyBadge.Render("READ-ONLY"))
+	}
 
 	// Context-sensitive help based on current view
 	contextHelp := ""
 	switch m.Focused {
-	case 0:
-		contextHelp = styles.StatusMessage.Render("Select a table with Enter or → | ? for help")
-	case 1:
-		if len(m.FilteredData) > 0 {
-			contextHelp = styles.StatusMessage.Render("Press v or Enter to view row details | / to search | ? for help")
+	case model.FocusTableList:
+		if m.ReadOnly {
+			contextHelp = styles.StatusMessage.Render("Select a relation or saved query with Enter or → | D shows its DDL | F picks a function | ? for help")
+		} else {
+			contextHelp = styles.StatusMessage.Render("Select a relation or saved query with Enter or → | D shows its DDL | F picks a function | ctrl+r refreshes a materialized view | ? for help")
+		}
+	case model.FocusTableData:
+		if m.TableTab != model.TabData {
+			contextHelp = styles.StatusMessage.Render("Tab switches to the next tab | Esc to go back | ? for help")
+		} else if len(m.FilteredData) > 0 {
+			contextHelp = styles.StatusMessage.Render(dataHelp(m))
 		} else {
 			contextHelp = styles.StatusMessage.Render("No data to display | Esc to go back | ? for help")
 		}
-	case 2:
-		contextHelp = styles.StatusMessage.Render("Viewing row details | Esc to go back | ? for help")
+	case model.FocusDetail:
+		if m.DetailReturn == model.FocusTableData && !m.ReadOnly {
+			contextHelp = styles.StatusMessage.Render("Viewing row details | ↑/↓ to pick a field | e to edit it | f follows a foreign key | r lists referencing rows | Esc to go back")
+		} else if m.DetailReturn == model.FocusTableData {
+			contextHelp = styles.StatusMessage.Render("Viewing row details | ↑/↓ to pick a field | f follows a foreign key | r lists referencing rows | Esc to go back")
+		} else {
+			contextHelp = styles.StatusMessage.Render("Viewing row details | Esc to go back | ? for help")
+		}
+	case model.FocusDDL:
+		contextHelp = styles.StatusMessage.Render("↑/↓ and PgUp/PgDn to scroll | y copies to the clipboard | w writes to a file | Esc to go back")
+	case model.FocusReferences:
+		contextHelp = styles.StatusMessage.Render("Enter opens the rows referencing this one | Esc to go back")
+	case model.FocusFunctions:
+		contextHelp = styles.StatusMessage.Render("Enter shows the DDL | / to filter | Esc to go back")
+	case model.FocusConnections:
+		contextHelp = styles.StatusMessage.Render("Select a connection with Enter | Esc to go back | ? for help")
+	case model.FocusChanges:
+		contextHelp = styles.StatusMessage.Render("Ctrl+S commits in one transaction | x rolls back all | d drops a change | Esc to go back")
+	case model.FocusInsert:
+		contextHelp = styles.StatusMessage.Render("Tab/↑/↓ to move | empty fields take the default, not '' | Ctrl+N to toggle NULL | Ctrl+S to insert | Esc to cancel")
+	case model.FocusParams:
+		contextHelp = styles.StatusMessage.Render("Tab/↑/↓ to move between parameters | Enter on the last one runs the statement | empty values are NULL | Esc to cancel")
+	case model.FocusHistory:
+		contextHelp = styles.StatusMessage.Render("Enter re-runs a statement | / to search | d deletes an entry, D clears the history | Esc to go back")
+	case model.FocusQuery:
+		if m.QueryResultsFocused {
+			contextHelp = styles.StatusMessage.Render("Press v or Enter to view row details | E to export | Tab or Esc to edit the query | ? for help")
+		} else {
+			contextHelp = styles.StatusMessage.Render("Ctrl+S or F5 runs the statement | Tab switches to the results | Esc to go back")
+		}
+	}
+
+	if m.Loading != "" {
+		contextHelp = lipgloss.JoinVertical(lipgloss.Left, contextHelp,
+			m.Spinner.View()+styles.Notice.Render(m.Loading+"...")+styles.StatusMessage.Render(" (ctrl+g to cancel)"))
+	}
+	if m.StatusMessage != "" {
+		contextHelp = lipgloss.JoinVertical(lipgloss.Left, contextHelp, styles.Notice.Render(m.StatusMessage))
+	}
+	if m.Editing {
+		editBar := styles.SearchPrompt.Render("✎ "+m.EditColumn+" = ") + m.EditInput.View() +
+			styles.StatusMessage.Render("  (Enter saves, ctrl+n sets NULL, Esc cancels)")
+		contextHelp = lipgloss.JoinVertical(lipgloss.Left, contextHelp, editBar)
 	}
 
 	// Help view
@@ -46,16 +102,48 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 	// Main content based on focused view
 	var content string
 
-	if m.Focused == 2 {
+	if m.Focused == model.FocusConnections {
+		header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("CONNECTIONS")
+		content = lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(m.ProfileList.View()))
+		if m.PasswordPrompt != "" {
+			prompt := styles.Notice.Render("Password for "+m.PasswordPrompt+":") + " " + m.PasswordInput.View()
+			content = lipgloss.JoinVertical(lipgloss.Left, content, prompt)
+		}
+	} else if m.Focused == model.FocusQuery {
+		content = renderQueryPane(m, styles)
+	} else if m.Focused == model.FocusParams {
+		content = renderParamsForm(m, styles)
+	} else if m.Focused == model.FocusInsert {
+		content = renderInsertForm(m, styles)
+	} else if m.Focused == model.FocusChanges {
+		content = renderChangesPane(m, styles)
+	} else if m.Focused == model.FocusHistory {
+		header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("QUERY HISTORY")
+		content = lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(m.HistoryList.View()))
+	} else if m.Focused == model.FocusDetail {
 		// Detail view
-		detailContent := RenderDetailView(m.SelectedRowData, m.Width-10, m.SelectedRow, styles)
+		var references map[string]string
+		if m.DetailReturn == model.FocusTableData {
+			references = referenceLabels(m.ForeignKeys)
+		}
+		detailContent := RenderDetailView(m.SelectedRowData, references, m.Width-10, m.SelectedRow, m.DetailCursor, styles)
 		content = styles.DetailCard.Width(m.Width - 10).Render(detailContent)
+	} else if m.Focused == model.FocusDDL {
+		header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("DDL: " + strings.ToUpper(m.DDLTitle))
+		position := styles.StatusMessage.Render(fmt.Sprintf("%3.f%%", m.DDLView.ScrollPercent()*100))
+		content = lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(m.DDLView.View()), position)
+	} else if m.Focused == model.FocusFunctions {
+		header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("FUNCTIONS")
+		content = lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(m.FunctionList.View()))
+	} else if m.Focused == model.FocusReferences {
+		header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("ROWS REFERENCING " + strings.ToUpper(m.SelectedTable.QualifiedName()))
+		content = lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(m.ReferenceList.View()))
 	} else {
 		// Table list view with title
-		tableListHeader := styles.TableListHeader.Render("DATABASE TABLES")
+		tableListHeader := styles.TableListHeader.Render("DATABASE RELATIONS")
 		tableListView := m.TableList.View()
 
-		if m.Focused == 0 {
+		if m.Focused == model.FocusTableList {
 			tableListView = styles.Focused.Render(tableListView)
 		} else {
 			tableListView = styles.Unfocused.Render(tableListView)
@@ -63,19 +151,33 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 
 		// Table data view with title
 		var tableDataView string
-		if m.SelectedTable != "" {
-			tableCount := fmt.Sprintf(" (%d rows)", len(m.Data))
-			tableDataHeader := styles.TableDataHeader.Render(fmt.Sprintf(" TABLE: %s%s ",
-				strings.ToUpper(m.SelectedTable),
+		if m.SelectedTable.Name != "" {
+			tableCount := " " + formatRowCount(m)
+			tableDataHeader := styles.TableDataHeader.Render(fmt.Sprintf(" %s: %s%s ",
+				strings.ToUpper(m.SelectedTable.KindLabel()),
+				strings.ToUpper(m.SelectedTable.QualifiedName()),
 				styles.StatusMessage.Render(tableCount)))
+			if m.Staging {
+				tableDataHeader += " " + styles.FilterIndicator.Render(fmt.Sprintf("STAGING · %d pending", len(m.PendingSQL)))
+			}
+			if m.Paging.Match != nil {
+				tableDataHeader = lipgloss.JoinVertical(lipgloss.Left, tableDataHeader, renderTrail(m, styles))
+			}
 
 			// Search UI
 			searchUI := ""
+			searchScope := "[local] "
+			if m.ServerSearch {
+				searchScope = "[server] "
+			}
 			if m.SearchMode {
-				searchUI = styles.SearchPrompt.Render("🔍 ") + m.SearchInput.View()
+				searchUI = styles.SearchPrompt.Render("🔍 "+searchScope) + m.SearchInput.View()
 			} else if m.SearchQuery != "" {
 				resultsCount := fmt.Sprintf(" (%d/%d rows)", len(m.FilteredData), len(m.Data))
-				searchUI = styles.SearchPrompt.Render("🔍 ") +
+				if m.Paging.Filter != "" {
+					resultsCount = " (filtered on server)"
+				}
+				searchUI = styles.SearchPrompt.Render("🔍 "+searchScope) +
 					styles.FilterIndicator.Render(m.SearchQuery) +
 					styles.StatusMessage.Render(resultsCount)
 			}
@@ -84,6 +186,8 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 			statusMsg := ""
 			if len(m.FilteredData) == 0 && m.SearchQuery != "" {
 				statusMsg = styles.StatusMessage.Render("No matching results. Press Ctrl+X to clear filter.")
+			} else if len(m.Data) == 0 && m.Paging.Match != nil {
+				statusMsg = styles.StatusMessage.Render("No rows match. Press Esc to go back.")
 			} else if len(m.Data) == 0 {
 				statusMsg = styles.StatusMessage.Render("Empty table")
 			}
@@ -112,10 +216,16 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 					scrollIndicator += styles.StatusMessage.Render(columnInfo)
 					scrollIndicator += " " + styles.StatusMessage.Render("(Shift+←/→ to scroll)")
 				}
+				if hidden := len(m.HiddenColumns); hidden > 0 {
+					if scrollIndicator != "" {
+						scrollIndicator += " "
+					}
+					scrollIndicator += styles.StatusMessage.Render(fmt.Sprintf("(%d hidden, + to show)", hidden))
+				}
 			}
 
 			dataView := m.TableData.View()
-			if m.Focused == 1 {
+			if m.Focused == model.FocusTableData {
 				dataView = styles.Focused.Render(dataView)
 			} else {
 				dataView = styles.Unfocused.Render(dataView)
@@ -123,11 +233,19 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 
 			tableDataView = lipgloss.JoinVertical(lipgloss.Left,
 				tableDataHeader,
+				renderTableTabs(m, styles),
 				searchUI,
 				statusMsg,
 				scrollIndicator,
 				dataView,
 			)
+			if m.TableTab != model.TabData {
+				tableDataView = lipgloss.JoinVertical(lipgloss.Left,
+					tableDataHeader,
+					renderTableTabs(m, styles),
+					renderTab(m, styles),
+				)
+			}
 		} else {
 			tableDataView = styles.Unfocused.Render(
 				lipgloss.Place(
@@ -148,6 +266,11 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 		)
 	}
 
+	if m.SavePrompt != "" {
+		prompt := styles.Notice.Render("Save "+m.SavePrompt+" to:") + " " + m.SaveInput.View()
+		content = lipgloss.JoinVertical(lipgloss.Left, content, prompt)
+	}
+
 	// Final layout
 	return styles.App.Render(lipgloss.JoinVertical(lipgloss.Left,
 		appTitle,
@@ -159,8 +282,179 @@ func RenderView(m *model.Model, styles *Styles, keys *KeyMap) string {
 	))
 }
 
-// RenderDetailView renders a detailed view of a row
-func RenderDetailView(data map[string]string, width int, rowIndex int, styles *Styles) string {
+// renderQueryPane renders the SQL editor with the outcome of the last
+// statement below it
+func renderQueryPane(m *model.Model, styles *Styles) string {
+	header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("SQL EDITOR")
+
+	editorView := m.QueryEditor.View()
+	if m.QueryResultsFocused {
+		editorView = styles.Unfocused.Render(editorView)
+	} else {
+		editorView = styles.Focused.Render(editorView)
+	}
+
+	parts := []string{header, editorView}
+	if m.QueryError != "" {
+		parts = append(parts, styles.Error.Render(m.QueryError))
+	} else if result := m.QueryResult; result != nil {
+		parts = append(parts, styles.TableDataHeader.Render(" "+formatQueryStatus(result)+" "))
+		if len(result.Columns) > 0 {
+			resultView := m.QueryTable.View()
+			if m.QueryResultsFocused {
+				resultView = styles.Focused.Render(resultView)
+			} else {
+				resultView = styles.Unfocused.Render(resultView)
+			}
+			parts = append(parts, resultView)
+		}
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, parts...)
+}
+
+// renderParamsForm renders the inputs for a statement's parameters below the
+// statement itself
+func renderParamsForm(m *model.Model, styles *Styles) string {
+	header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("PARAMETERS: " + strings.ToUpper(m.ParamTitle))
+
+	labelWidth := 0
+	for _, name := range m.ParamNames {
+		labelWidth = utils.Max(labelWidth, len(name)+2)
+	}
+	rows := []string{styles.StatusMessage.Render(m.ParamSource), ""}
+	for i, name := range m.ParamNames {
+		label := styles.DetailLabel.Copy().Width(labelWidth).Render(name + ":")
+		rows = append(rows, label+" "+m.ParamInputs[i].View())
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+}
+
+// renderInsertForm renders an input for every writable column of the
+// selected table, with its type and whether it may be left empty
+func renderInsertForm(m *model.Model, styles *Styles) string {
+	header := styles.TableListHeader.Copy().Width(m.Width - 10).Render("INSERT INTO " + strings.ToUpper(m.SelectedTable.QualifiedName()))
+
+	labelWidth, typeWidth := 0, 0
+	for _, column := range m.InsertColumns {
+		labelWidth = utils.Max(labelWidth, len(column.Name)+2)
+		typeWidth = utils.Max(typeWidth, len(column.Type))
+	}
+	var rows []string
+	for i, column := range m.InsertColumns {
+		name := column.Name
+		if !column.Nullable && column.Default == "" && !column.Identity {
+			name += "*"
+		}
+		label := styles.DetailLabel.Copy().Width(labelWidth).Render(name + ":")
+		typeHint := styles.StatusMessage.Copy().Width(typeWidth).Render(column.Type)
+		input := m.InsertInputs[i].View()
+		if m.InsertNull[i] {
+			input = styles.DetailNull.Render(NullMarker + " NULL")
+		}
+		rows = append(rows, label+" "+typeHint+" "+input)
+	}
+	rows = append(rows, "", styles.StatusMessage.Render("* required | empty fields take their default"))
+	if m.InsertError != "" {
+		rows = append(rows, styles.Error.Render(m.InsertError))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+}
+
+// renderTableTabs renders the tab bar of the table view
+func renderTableTabs(m *model.Model, styles *Styles) string {
+	tabs := make([]string, len(model.TableTabs))
+	for i, title := range model.TableTabs {
+		if i == m.TableTab {
+			tabs[i] = styles.FilterIndicator.Render("[" + title + "]")
+		} else {
+			tabs[i] = styles.StatusMessage.Render(" " + title + " ")
+		}
+	}
+	return strings.Join(tabs, " ") + styles.StatusMessage.Render("  (Tab to switch)")
+}
+
+// renderTab renders the grid of a table view tab other than the data
+func renderTab(m *model.Model, styles *Styles) string {
+	if m.TabEmpty != "" {
+		return styles.StatusMessage.Render(m.TabEmpty)
+	}
+	view := m.TabTable.View()
+	if m.Focused == model.FocusTableData {
+		return styles.Focused.Render(view)
+	}
+	return styles.Unfocused.Render(view)
+}
+
+// dataHelp returns the context help for the data grid, leaving out editing
+// on read-only connections
+func dataHelp(m *model.Model) string {
+	if m.ReadOnly {
+		return "Press v or Enter to view row details | / to search | s/S to sort | E/A to export | ? for help"
+	}
+	return "Press v or Enter to view row details | ←/→ to pick a column | e to edit it | s/S to sort it | / to search | E/A to export | ? for help"
+}
+
+// renderChangesPane lists the statements of the pending changeset for review
+func renderChangesPane(m *model.Model, styles *Styles) string {
+	header := styles.TableListHeader.Copy().Width(m.Width - 10).Render(fmt.Sprintf("PENDING CHANGES (%d)", len(m.PendingSQL)))
+
+	var rows []string
+	for i, sql := range m.PendingSQL {
+		marker := "  "
+		if i == m.ChangesCursor {
+			marker = styles.ScrollIndicator.Render("› ")
+		}
+		number := styles.StatusMessage.Render(fmt.Sprintf("%3d ", i+1))
+		rows = append(rows, marker+number+styles.DetailValue.Copy().Width(m.Width-20).Render(sql))
+	}
+	if m.ChangesError != "" {
+		rows = append(rows, "", styles.Error.Copy().Width(m.Width-14).Render(m.ChangesError))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, header, styles.Focused.Render(lipgloss.JoinVertical(lipgloss.Left, rows...)))
+}
+
+// formatQueryStatus summarizes a statement's outcome: its command tag, the
+// rows returned or affected and how long it took
+func formatQueryStatus(result *model.QueryResult) string {
+	status := []string{result.CommandTag}
+	switch {
+	case result.Truncated:
+		status = append(status, fmt.Sprintf("showing the first %d rows", len(result.Rows)))
+	case len(result.Columns) > 0:
+		status = append(status, fmt.Sprintf("%d rows", len(result.Rows)))
+	case result.RowsAffected > 0:
+		status = append(status, fmt.Sprintf("%d rows affected", result.RowsAffected))
+	}
+	status = append(status, formatDuration(result.Duration))
+	return strings.Join(status, " · ")
+}
+
+// formatDuration shows a query duration with a precision that suits it
+func formatDuration(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return d.Round(time.Microsecond).String()
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	default:
+		return d.Round(10 * time.Millisecond).String()
+	}
+}
+
+// DetailFields returns the fields of a row in the order the detail view
+// lists them
+func DetailFields(data map[string]model.Cell) []string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RenderDetailView renders a detailed view of a row, marking the field under
+// the cursor
+func RenderDetailView(data map[string]model.Cell, references map[string]string, width int, rowIndex int, cursor int, styles *Styles) string {
 	if len(data) == 0 {
 		return "No data available"
 	}
@@ -181,11 +475,7 @@ func RenderDetailView(data map[string]string, width int, rowIndex int, styles *S
 	}
 
 	// Sort keys alphabetically
-	var keys []string
-	for k := range data {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
+	keys := DetailFields(data)
 
 	// Build rows
 	var rows []string
@@ -193,21 +483,75 @@ func RenderDetailView(data map[string]string, width int, rowIndex int, styles *S
 	rows = append(rows, "")
 
 	// Add all fields
-	for _, k := range keys {
+	for i, k := range keys {
 		v := data[k]
 
 		// Format the value nicely
-		formattedValue := v
-		if v == "NULL" {
+		formattedValue := v.Value
+		if v.Null {
 			formattedValue = styles.DetailNull.Render("NULL")
 		}
 
 		label := styles.DetailLabel.Copy().Width(maxKeyLen + 2).Render(k + ":")
 		value := styles.DetailValue.Render(formattedValue)
+		if target, ok := references[k]; ok {
+			value += " " + styles.ScrollIndicator.Render("→ "+target)
+		}
 
-		row := fmt.Sprintf("%s %s", label, value)
+		marker := "  "
+		if i == cursor {
+			marker = styles.ScrollIndicator.Render("› ")
+		}
+		row := fmt.Sprintf("%s%s %s", marker, label, value)
 		rows = append(rows, row)
 	}
 
 	return lipgloss.JoinVertical(lipgloss.Left, rows...)
 }
+
+// referenceLabels describes, for each foreign key column, the columns it
+// references
+func referenceLabels(keys []model.ForeignKey) map[string]string {
+	labels := make(map[string]string)
+	for _, fk := range keys {
+		target := fmt.Sprintf("%s(%s)", fk.RefTable.QualifiedName(), strings.Join(fk.RefColumns, ", "))
+		for _, column := range fk.Columns {
+			if _, ok := labels[column]; !ok {
+				labels[column] = target
+			}
+		}
+	}
+	return labels
+}
+
+// renderTrail shows the relations followed to reach the selected one and the
+// condition its rows are limited to
+func renderTrail(m *model.Model, styles *Styles) string {
+	trail := append(append([]string(nil), m.Breadcrumbs...), m.SelectedTable.QualifiedName())
+	return styles.StatusMessage.Render(strings.Join(trail, " › ")+" | where ") +
+		styles.FilterIndicator.Render(m.Paging.Match.String())
+}
+
+// formatRowCount describes how many rows are loaded versus the relation total
+func formatRowCount(m *model.Model) string {
+	loaded := len(m.Data)
+	var count string
+	switch {
+	case m.Paging.Filter != "" && m.Paging.HasMore:
+		count = fmt.Sprintf("(%d+ matching rows loaded)", loaded)
+	case m.Paging.Filter != "":
+		count = fmt.Sprintf("(%d matching rows)", loaded)
+	case !m.Paging.HasMore:
+		count = fmt.Sprintf("(%d rows)", loaded)
+	case m.Paging.TotalRows < 0:
+		count = fmt.Sprintf("(%d+ rows loaded)", loaded)
+	case m.Paging.Estimated:
+		count = fmt.Sprintf("(%d of ~%d rows)", loaded, m.Paging.TotalRows)
+	default:
+		count = fmt.Sprintf("(%d of %d rows)", loaded, m.Paging.TotalRows)
+	}
+	if m.Paging.Loading {
+		count += " loading…"
+	}
+	return count
+}
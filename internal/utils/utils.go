@@ -1,5 +1,7 @@
 package utils
 
+import "fmt"
+
 // Min returns the smaller of two integers
 func Min(a, b int) int {
 	if a < b {
@@ -8,6 +10,14 @@ func Min(a, b int) int {
 	return b
 }
 
+// Max returns the larger of two integers
+func Max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // Contains checks if a string slice contains a specific string
 func Contains(slice []string, item string) bool {
 	for _, s := range slice {
@@ -17,3 +27,18 @@ func Contains(slice []string, item string) bool {
 	}
 	return false
 }
+
+// UniqueNames numbers repeated names, "name (2)", "name (3)" and so on, so
+// that columns like the two ?column? of SELECT 1, 2 can key a map
+func UniqueNames(names []string) []string {
+	unique := make([]string, len(names))
+	taken := make(map[string]bool, len(names))
+	for i, name := range names {
+		unique[i] = name
+		for n := 2; taken[unique[i]]; n++ {
+			unique[i] = fmt.Sprintf("%s (%d)", name, n)
+		}
+		taken[unique[i]] = true
+	}
+	return unique
+}
@@ -1,59 +1,134 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"strings"
 	"time"
 
+	"github.com/mattn/go-isatty"
+
 	"github.com/ddoemonn/go-dot-dot/internal/app"
 	"github.com/ddoemonn/go-dot-dot/internal/config"
+	"github.com/ddoemonn/go-dot-dot/internal/db"
 )
 
-func main() {
-	// Check if .env file exists before loading configuration
-	envExists := false
-	if _, err := os.Stat(".env"); err == nil {
-		envExists = true
-	}
+// options holds the command-line flags
+type options struct {
+	profile  string
+	table    string
+	noSetup  bool
+	override config.DBConfig // Connection settings that override the configuration
+}
 
-	// Load configuration
-	cfg, err := config.Load()
+func parseFlags() options {
+	var opts options
+	flag.StringVar(&opts.override.Host, "host", "", "database server host or socket directory")
+	flag.StringVar(&opts.override.Port, "port", "", "database server port")
+	flag.StringVar(&opts.override.Name, "dbname", "", "database name")
+	flag.StringVar(&opts.override.User, "user", "", "database user")
+	flag.StringVar(&opts.override.DSN, "dsn", "", "full connection URL or key=value string")
+	flag.StringVar(&opts.profile, "profile", "", "connect using a named profile from the profiles file")
+	flag.StringVar(&opts.table, "table", "", "open this relation on start, e.g. public.users")
+	flag.BoolVar(&opts.override.ReadOnly, "read-only", false, "open every transaction read-only")
+	flag.BoolVar(&opts.noSetup, "no-setup", false, "never run the setup wizard; fail when nothing is configured")
+	flag.Parse()
+	return opts
+}
+
+// hasConnectionFlags reports whether connection settings were given on the
+// command line, which is enough to connect without any configuration
+func (o options) hasConnectionFlags() bool {
+	d := o.override
+	return d.DSN != "" || d.Host != "" || d.Port != "" || d.Name != "" || d.User != ""
+}
+
+// loadConfig builds the configuration from a profile, .env or the wizard and
+// applies the command-line overrides. It also reports whether the wizard ran.
+func loadConfig(opts options, interactive bool) (cfg *config.Config, ranSetup bool, err error) {
+	switch {
+	case opts.profile != "":
+		cfg, err = config.LoadProfile(opts.profile)
+	case opts.hasConnectionFlags():
+		cfg, err = config.Load(false)
+		if errors.Is(err, config.ErrNotConfigured) {
+			cfg, err = config.Default(), nil
+		}
+	default:
+		cfg, err = config.Load(false)
+		// The wizard needs a terminal to run in
+		if errors.Is(err, config.ErrNotConfigured) && interactive && !opts.noSetup {
+			cfg, err = config.RunSetup()
+			ranSetup = true
+		}
+	}
 	if err != nil {
-		// If setup was cancelled but .env file exists, try to continue
-		if strings.Contains(err.Error(), "setup cancelled") && envExists {
-			fmt.Println("Configuration exists. Continuing with saved configuration...")
-			// Try to load again directly
-			cfg, err = config.LoadFromEnv()
-			if err != nil {
-				log.Fatalf("Failed to load configuration: %v", err)
-			}
-		} else if strings.Contains(err.Error(), "setup cancelled") {
-			fmt.Println("Configuration setup cancelled. Exiting...")
-			os.Exit(0)
-		} else {
-			log.Printf("Warning: %v", err)
-			fmt.Println("Continuing with default configuration.")
+		return nil, ranSetup, err
+	}
+
+	if err := cfg.DB.Override(opts.override); err != nil {
+		return nil, ranSetup, err
+	}
+
+	// Profiles that don't store their password ask for it on every start
+	if cfg.PromptPassword && cfg.DB.Password == "" {
+		if !interactive {
+			return nil, ranSetup, fmt.Errorf("profile %q asks for its password, which needs a terminal", cfg.Profile)
+		}
+		cfg.DB.Password, err = config.PromptPassword(cfg.Profile)
+		if err != nil {
+			return nil, ranSetup, err
 		}
 	}
+	return cfg, ranSetup, nil
+}
 
-	// Give a moment for the user to see the success message
-	time.Sleep(500 * time.Millisecond)
+// fail prints an error and exits with a non-zero status
+func fail(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
 
-	// Clear the screen after setup
-	fmt.Print("\033[H\033[2J")
+func main() {
+	opts := parseFlags()
+	interactive := isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd())
+
+	// Load configuration
+	cfg, ranSetup, err := loadConfig(opts, interactive)
+	switch {
+	case errors.Is(err, config.ErrSetupCancelled):
+		fmt.Println("Configuration setup cancelled. Exiting...")
+		os.Exit(0)
+	case errors.Is(err, config.ErrNotConfigured):
+		fail("No connection settings found. Pass --dsn or --host, use --profile, or run in a terminal without --no-setup to start the setup wizard.")
+	case errors.Is(err, config.ErrProfileNotFound):
+		fail("%v", err)
+	case err != nil:
+		fail("Failed to load configuration: %v", err)
+	}
+
+	// After the setup wizard, give a moment for the user to see its success
+	// message, then clear the screen
+	if ranSetup {
+		time.Sleep(500 * time.Millisecond)
+		fmt.Print("\033[H\033[2J")
+	}
 	fmt.Println("Starting PostgreSQL Database Explorer...")
 
 	// Initialize and run the application
-	application, err := app.New(cfg)
-	if err != nil {
-		if strings.Contains(err.Error(), "connect") || strings.Contains(err.Error(), "database") {
-			fmt.Println("\nError connecting to database. Please check your configuration in .env file.")
-			fmt.Println("You can edit the .env file manually or delete it to run the setup wizard again.")
-			fmt.Printf("\nError details: %v\n", err)
-			os.Exit(1)
+	application, err := app.New(cfg, app.Options{Table: opts.table, ReadOnly: opts.override.ReadOnly})
+	switch {
+	case errors.Is(err, db.ErrConnect):
+		fmt.Fprintln(os.Stderr, "\nError connecting to database. Please check your connection settings.")
+		if path, pathErr := config.ProfilesPath(); pathErr == nil {
+			fmt.Fprintf(os.Stderr, "Profiles are saved in %s; delete it to run the setup wizard again.\n", path)
 		}
+		fail("\nError details: %v", err)
+	case errors.Is(err, db.ErrTableNotFound):
+		fail("%v", err)
+	case err != nil:
 		log.Fatalf("Failed to initialize application: %v", err)
 	}
 